@@ -0,0 +1,89 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package concurrent
+
+import "context"
+
+// Chan is a buffered channel built on MPMCQueueValue.
+// It follows the semantics of a buffered Go channel:
+// sending on a closed Chan or closing it twice panics,
+// receiving from a closed Chan drains the remaining values first.
+// Blocked senders and receivers park until the opposite side wakes them up
+type Chan[T any] struct {
+	q *MPMCQueueValue[T]
+}
+
+// NewChan creates a new Chan with the given capacity,
+// which is rounded up to a power of 2 and must be at least 2
+func NewChan[T any](capacity int) *Chan[T] {
+	_, p := NewMPMCQueueValue[T](capacity)
+
+	return &Chan[T]{q: p.(*MPMCQueueValue[T])}
+}
+
+// Send sends v, it blocks while the channel is full
+func (c *Chan[T]) Send(v T) {
+	err := c.q.Enqueue(v)
+	if err == ErrTemporaryUnavailable {
+		_ = c.q.notFull.wait(context.Background(), func() bool {
+			err = c.q.Enqueue(v)
+			return err != ErrTemporaryUnavailable
+		})
+	}
+	if err == ErrClosed {
+		panic("send on closed channel")
+	}
+}
+
+// TrySend sends v if the channel is not full and reports whether it has been sent
+func (c *Chan[T]) TrySend(v T) bool {
+	err := c.q.Enqueue(v)
+	if err == ErrClosed {
+		panic("send on closed channel")
+	}
+
+	return err == nil
+}
+
+// Recv receives a value, it blocks while the channel is empty.
+// ok is false if the channel is closed and drained
+func (c *Chan[T]) Recv() (v T, ok bool) {
+	v, err := c.q.Dequeue()
+	if err == ErrTemporaryUnavailable {
+		_ = c.q.notEmpty.wait(context.Background(), func() bool {
+			v, err = c.q.Dequeue()
+			return err != ErrTemporaryUnavailable
+		})
+	}
+
+	return v, err == nil
+}
+
+// TryRecv receives a value if the channel is not empty
+// and reports whether a value has been received
+func (c *Chan[T]) TryRecv() (v T, ok bool) {
+	v, err := c.q.Dequeue()
+
+	return v, err == nil
+}
+
+// Close closes the channel, blocked senders panic
+// and blocked receivers return once the channel is drained.
+// Every value of a Send racing with Close which returns is received
+func (c *Chan[T]) Close() {
+	if c.q.Close() != nil {
+		panic("close of closed channel")
+	}
+}
+
+// Len returns the approximate number of buffered values
+func (c *Chan[T]) Len() int {
+	return c.q.Len()
+}
+
+// Cap returns the capacity of the channel
+func (c *Chan[T]) Cap() int {
+	return c.q.Cap()
+}
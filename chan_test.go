@@ -0,0 +1,232 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package concurrent_test
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"code.hybscloud.com/concurrent"
+)
+
+func TestChan(t *testing.T) {
+	t.Run("send recv", func(t *testing.T) {
+		ch := concurrent.NewChan[int](4)
+		if ch.Cap() != 4 {
+			t.Errorf("cap expected 4 but got %v", ch.Cap())
+			return
+		}
+		_, ok := ch.TryRecv()
+		if ok {
+			t.Error("try recv expected nothing on empty channel")
+			return
+		}
+		for i := range 4 {
+			ch.Send(100 + i)
+		}
+		if ch.TrySend(104) {
+			t.Error("try send expected failure on full channel")
+			return
+		}
+		if ch.Len() != 4 {
+			t.Errorf("len expected 4 but got %v", ch.Len())
+			return
+		}
+		for i := range 4 {
+			v, ok := ch.Recv()
+			if !ok || v != 100+i {
+				t.Errorf("recv expected %v, true but got %v, %v", 100+i, v, ok)
+				return
+			}
+		}
+	})
+
+	t.Run("close", func(t *testing.T) {
+		ch := concurrent.NewChan[int](4)
+		ch.Send(1)
+		ch.Close()
+		v, ok := ch.Recv()
+		if !ok || v != 1 {
+			t.Errorf("recv expected 1, true but got %v, %v", v, ok)
+			return
+		}
+		v, ok = ch.Recv()
+		if ok || v != 0 {
+			t.Errorf("recv expected 0, false but got %v, %v", v, ok)
+			return
+		}
+		expectPanic(t, "send on closed channel", func() { ch.Send(2) })
+		expectPanic(t, "send on closed channel", func() { ch.TrySend(2) })
+		expectPanic(t, "close of closed channel", ch.Close)
+	})
+
+	t.Run("close wakes up receivers", func(t *testing.T) {
+		ch := concurrent.NewChan[int](4)
+		wg := sync.WaitGroup{}
+		for range 4 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, ok := ch.Recv()
+				if ok {
+					t.Error("recv expected false on closed channel")
+				}
+			}()
+		}
+		time.Sleep(time.Millisecond)
+		ch.Close()
+		wg.Wait()
+	})
+
+	t.Run("close wakes up senders", func(t *testing.T) {
+		ch := concurrent.NewChan[int](2)
+		ch.Send(1)
+		ch.Send(2)
+		done := make(chan any)
+		go func() {
+			defer func() { done <- recover() }()
+			ch.Send(3)
+		}()
+		time.Sleep(time.Millisecond)
+		ch.Close()
+		if r := <-done; r != "send on closed channel" {
+			t.Errorf("blocked send expected panic but got %v", r)
+		}
+	})
+
+	t.Run("close races with send", func(t *testing.T) {
+		defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(8))
+		for range 1 << 10 {
+			ch := concurrent.NewChan[int](64)
+			sent, received := atomic.Int64{}, atomic.Int64{}
+			swg := sync.WaitGroup{}
+			for range 4 {
+				swg.Add(1)
+				go func() {
+					defer swg.Done()
+					defer func() {
+						if r := recover(); r != "send on closed channel" {
+							t.Errorf("send expected panic but got %v", r)
+						}
+					}()
+					for {
+						ch.Send(1)
+						sent.Add(1)
+					}
+				}()
+			}
+			rwg := sync.WaitGroup{}
+			for range 4 {
+				rwg.Add(1)
+				go func() {
+					defer rwg.Done()
+					for {
+						_, ok := ch.Recv()
+						if !ok {
+							return
+						}
+						received.Add(1)
+					}
+				}()
+			}
+			for sent.Load() < 8 {
+				runtime.Gosched()
+			}
+			ch.Close()
+			swg.Wait()
+			rwg.Wait()
+			if sent.Load() != received.Load() {
+				t.Fatalf("sent %v values but received %v", sent.Load(), received.Load())
+			}
+		}
+	})
+
+	for _, rn := range []int{1, 4, 16} {
+		for _, sn := range []int{1, 4, 16} {
+			t.Run(fmt.Sprintf("%d receivers %d senders", rn, sn), func(t *testing.T) {
+				const n = 1 << 12
+				ch := concurrent.NewChan[int64](64)
+				swg := sync.WaitGroup{}
+				for range sn {
+					swg.Add(1)
+					go func() {
+						defer swg.Done()
+						for j := range n {
+							ch.Send(int64(j))
+						}
+					}()
+				}
+				sum := atomic.Int64{}
+				rwg := sync.WaitGroup{}
+				for range rn {
+					rwg.Add(1)
+					go func() {
+						defer rwg.Done()
+						for {
+							v, ok := ch.Recv()
+							if !ok {
+								return
+							}
+							sum.Add(v)
+						}
+					}()
+				}
+				swg.Wait()
+				ch.Close()
+				rwg.Wait()
+				if expected := int64(sn * n * (n - 1) / 2); sum.Load() != expected {
+					t.Errorf("sum expected %v but got %v", expected, sum.Load())
+				}
+			})
+		}
+	}
+}
+
+func expectPanic(t *testing.T, msg string, f func()) {
+	t.Helper()
+	defer func() {
+		if r := recover(); r != msg {
+			t.Errorf("expected panic %q but got %v", msg, r)
+		}
+	}()
+	f()
+}
+
+func BenchmarkChan(b *testing.B) {
+	for _, n := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("Chan %d senders %d receivers", n, n), func(b *testing.B) {
+			ch := concurrent.NewChan[int64](1 << 10)
+			benchmarkChan(b, n, ch.Send, func() { ch.Recv() })
+		})
+		b.Run(fmt.Sprintf("chan %d senders %d receivers", n, n), func(b *testing.B) {
+			ch := make(chan int64, 1<<10)
+			benchmarkChan(b, n, func(v int64) { ch <- v }, func() { <-ch })
+		})
+	}
+}
+
+func benchmarkChan(b *testing.B, n int, send func(int64), recv func()) {
+	wg := sync.WaitGroup{}
+	for range n {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < b.N/n; j++ {
+				send(int64(j))
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < b.N/n; j++ {
+				recv()
+			}
+		}()
+	}
+	wg.Wait()
+}
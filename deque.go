@@ -0,0 +1,141 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package concurrent
+
+import (
+	"math"
+	"sync/atomic"
+
+	"golang.org/x/sys/cpu"
+)
+
+// WorkStealingDeque represents Chase-Lev work-stealing deque.
+// The owner pushes and pops items at the bottom in LIFO order,
+// any other goroutine steals items from the top in FIFO order.
+// Push and Pop must only be called by the owner goroutine,
+// Steal is safe to call from any goroutine
+type WorkStealingDeque[T any] struct {
+	_      noCopy
+	top    atomic.Int64
+	_      cpu.CacheLinePad
+	bottom atomic.Int64
+	_      cpu.CacheLinePad
+	ring   atomic.Pointer[dequeRing[T]]
+}
+
+// NewWorkStealingDeque creates a new unbounded work-stealing deque.
+// The circular array starts with the given capacity rounded up to a power of 2
+// and doubles whenever it is full
+func NewWorkStealingDeque[T any](capacity int) *WorkStealingDeque[T] {
+	order := capacityOrder(capacity)
+	q := WorkStealingDeque[T]{}
+	q.ring.Store(newDequeRing[T](order))
+
+	return &q
+}
+
+// Push pushes the given item at the bottom, it never returns ErrTemporaryUnavailable
+func (q *WorkStealingDeque[T]) Push(elem *T) error {
+	b, t := q.bottom.Load(), q.top.Load()
+	r := q.ring.Load()
+	if b-t >= int64(len(r.slots)) {
+		r = r.grow(t, b)
+		q.ring.Store(r)
+	}
+	r.slot(b).Store(elem)
+	q.bottom.Store(b + 1)
+
+	return nil
+}
+
+// Pop pops the most recently pushed item from the bottom.
+// if the deque is empty, ErrTemporaryUnavailable will be returned
+func (q *WorkStealingDeque[T]) Pop() (elem *T, err error) {
+	b := q.bottom.Load() - 1
+	r := q.ring.Load()
+	q.bottom.Store(b)
+	t := q.top.Load()
+	if t > b {
+		q.bottom.Store(b + 1)
+		return nil, ErrTemporaryUnavailable
+	}
+	elem = r.slot(b).Load()
+	if t < b {
+		r.slot(b).Store(nil)
+		return elem, nil
+	}
+	// the last item, race against thieves for it
+	if !q.top.CompareAndSwap(t, t+1) {
+		elem, err = nil, ErrTemporaryUnavailable
+	}
+	q.bottom.Store(b + 1)
+
+	return
+}
+
+// Steal steals the least recently pushed item from the top.
+// if the deque is empty, ErrTemporaryUnavailable will be returned.
+// if another goroutine took the item first, ErrAborted will be returned
+func (q *WorkStealingDeque[T]) Steal() (elem *T, err error) {
+	t := q.top.Load()
+	b := q.bottom.Load()
+	if t >= b {
+		return nil, ErrTemporaryUnavailable
+	}
+	elem = q.ring.Load().slot(t).Load()
+	if !q.top.CompareAndSwap(t, t+1) {
+		return nil, ErrAborted
+	}
+
+	return elem, nil
+}
+
+// Len returns the approximate number of items in the deque
+func (q *WorkStealingDeque[T]) Len() int {
+	return clampLen(q.bottom.Load()-q.top.Load(), math.MaxInt)
+}
+
+// Cap returns math.MaxInt since the deque is unbounded
+func (q *WorkStealingDeque[T]) Cap() int {
+	return math.MaxInt
+}
+
+// IsEmpty reports whether the deque is approximately empty
+func (q *WorkStealingDeque[T]) IsEmpty() bool {
+	return q.Len() == 0
+}
+
+// IsFull always returns false since the deque is unbounded
+func (q *WorkStealingDeque[T]) IsFull() bool {
+	return false
+}
+
+// circular array of a work-stealing deque
+//
+// Stolen slots are not cleared because the owner may have reused them,
+// they are overwritten by later pushes or dropped with the array
+type dequeRing[T any] struct {
+	slots []atomic.Pointer[T]
+	mask  int64
+}
+
+func newDequeRing[T any](order int) *dequeRing[T] {
+	return &dequeRing[T]{slots: make([]atomic.Pointer[T], 1<<order), mask: 1<<order - 1}
+}
+
+func (r *dequeRing[T]) slot(i int64) *atomic.Pointer[T] {
+	return &r.slots[i&r.mask]
+}
+
+// grow returns a ring twice as large holding the items between t and b.
+// Thieves may still read the old ring, its slots are left untouched
+func (r *dequeRing[T]) grow(t, b int64) *dequeRing[T] {
+	n := &dequeRing[T]{slots: make([]atomic.Pointer[T], 2*len(r.slots)), mask: 2*r.mask + 1}
+	for i := t; i < b; i++ {
+		n.slot(i).Store(r.slot(i).Load())
+	}
+
+	return n
+}
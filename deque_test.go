@@ -0,0 +1,114 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package concurrent_test
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"code.hybscloud.com/concurrent"
+)
+
+func TestWorkStealingDeque(t *testing.T) {
+	t.Run("owner and thief order", func(t *testing.T) {
+		q := concurrent.NewWorkStealingDeque[int](2)
+		_, err := q.Pop()
+		if err != concurrent.ErrTemporaryUnavailable {
+			t.Errorf("pop expected ErrTemporaryUnavailable but got %v", err)
+			return
+		}
+		_, err = q.Steal()
+		if err != concurrent.ErrTemporaryUnavailable {
+			t.Errorf("steal expected ErrTemporaryUnavailable but got %v", err)
+			return
+		}
+		items := make([]int, 100)
+		for i := range items {
+			items[i] = i
+			_ = q.Push(&items[i])
+		}
+		if q.Len() != 100 {
+			t.Errorf("len expected 100 but got %v", q.Len())
+			return
+		}
+		for i := range 50 {
+			elem, err := q.Steal()
+			if err != nil || *elem != i {
+				t.Errorf("steal expected %v, <nil> but got %v, %v", i, elem, err)
+				return
+			}
+		}
+		for i := 99; i >= 50; i-- {
+			elem, err := q.Pop()
+			if err != nil || *elem != i {
+				t.Errorf("pop expected %v, <nil> but got %v, %v", i, elem, err)
+				return
+			}
+		}
+		if !q.IsEmpty() {
+			t.Error("deque expected empty")
+		}
+	})
+
+	for _, n := range []int{1, 4, 16} {
+		t.Run(fmt.Sprintf("owner with %d thieves", n), func(t *testing.T) {
+			const m = 1 << 14
+			q := concurrent.NewWorkStealingDeque[int64](4)
+			items := make([]int64, m)
+			taken := make([]atomic.Int32, m)
+			sum := atomic.Int64{}
+			count := atomic.Int64{}
+			take := func(elem *int64) {
+				if taken[*elem].Add(1) != 1 {
+					t.Errorf("item %v taken twice", *elem)
+				}
+				sum.Add(*elem)
+				count.Add(1)
+			}
+			wg := sync.WaitGroup{}
+			for range n {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for count.Load() < m {
+						elem, err := q.Steal()
+						if err == nil {
+							take(elem)
+						}
+					}
+				}()
+			}
+			for i := range items {
+				items[i] = int64(i)
+				_ = q.Push(&items[i])
+				if i%3 == 0 {
+					if elem, err := q.Pop(); err == nil {
+						take(elem)
+					}
+				}
+			}
+			for count.Load() < m {
+				if elem, err := q.Pop(); err == nil {
+					take(elem)
+				}
+			}
+			wg.Wait()
+			if expected := int64(m * (m - 1) / 2); sum.Load() != expected {
+				t.Errorf("sum expected %v but got %v", expected, sum.Load())
+			}
+		})
+	}
+}
+
+func BenchmarkWorkStealingDeque(b *testing.B) {
+	q := concurrent.NewWorkStealingDeque[int](1 << 10)
+	item := 0
+	for i := 0; i < b.N; i++ {
+		_ = q.Push(&item)
+		_, _ = q.Pop()
+	}
+}
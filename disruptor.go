@@ -0,0 +1,330 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package concurrent
+
+import (
+	"math"
+	"slices"
+	"sync/atomic"
+
+	"golang.org/x/sys/cpu"
+)
+
+// WaitStrategy decides how producers and consumers of a Disruptor wait.
+// Wait is called once per unsuccessful attempt, sw is owned by the waiting
+// goroutine and starts from its zero value for every wait
+type WaitStrategy interface {
+	Wait(sw *SpinWait)
+}
+
+// SpinWaitStrategy waits with SpinWait, it spins and yields
+// the processor more and more often. Once it has yielded
+// spinWaitStrategyYields times it sleeps with Yield, so that spinning
+// threads do not starve the goroutines they wait for
+// when goroutines outnumber CPUs
+type SpinWaitStrategy struct{}
+
+const spinWaitStrategyYields = 32
+
+// Wait performs a single spin of sw, or sleeps once sw has yielded enough
+func (SpinWaitStrategy) Wait(sw *SpinWait) {
+	if sw.n >= spinWaitStrategyYields {
+		Yield()
+		return
+	}
+	sw.Once()
+}
+
+// YieldWaitStrategy waits with Yield at the given backoff level,
+// the zero level only yields the processor without sleeping
+type YieldWaitStrategy struct {
+	Level int
+}
+
+// Wait yields the processor once
+func (w YieldWaitStrategy) Wait(*SpinWait) {
+	Yield(w.Level)
+}
+
+// Disruptor represents LMAX Disruptor style multicast ring buffer.
+// Producers claim sequences, fill the pre-allocated entries in place
+// and publish them. Every consumer observes every published entry
+// and may depend on other consumers, so that it only sees the entries
+// which its dependencies have already processed.
+// The producers never overwrite an entry that a consumer has not processed yet
+type Disruptor[T any] struct {
+	_ noCopy
+	// multiple producers: the highest claimed sequence
+	// single producer: the highest published sequence
+	cursor atomic.Int64
+	_      cpu.CacheLinePad
+	// single producer: the highest claimed sequence
+	next int64
+	// cached minimum sequence of consumers
+	gating atomic.Int64
+	_      cpu.CacheLinePad
+	// multiple producers: the round of sequence published in each slot
+	published []atomic.Int64
+	entries   []T
+	mask      int64
+	order     int
+	consumers atomic.Pointer[[]*DisruptorConsumer[T]]
+	ws        WaitStrategy
+}
+
+// NewDisruptor creates a new Disruptor with the given capacity for
+// multiple producers, the capacity is rounded up to a power of 2.
+// If ws is nil, SpinWaitStrategy is used.
+// Consumers created with NewConsumer after the first publish
+// only observe the entries published after them
+func NewDisruptor[T any](capacity int, ws WaitStrategy) *Disruptor[T] {
+	d := newDisruptor[T](capacity, ws)
+	d.published = make([]atomic.Int64, len(d.entries))
+	for i := range d.published {
+		d.published[i].Store(-1)
+	}
+
+	return d
+}
+
+// NewDisruptorSingleProducer creates a new Disruptor with the given capacity
+// for a single producer, the capacity is rounded up to a power of 2.
+// If ws is nil, SpinWaitStrategy is used.
+// Consumers created with NewConsumer after the first publish
+// only observe the entries published after them
+func NewDisruptorSingleProducer[T any](capacity int, ws WaitStrategy) *Disruptor[T] {
+	return newDisruptor[T](capacity, ws)
+}
+
+func newDisruptor[T any](capacity int, ws WaitStrategy) *Disruptor[T] {
+	order := capacityOrder(capacity)
+	if ws == nil {
+		ws = SpinWaitStrategy{}
+	}
+	d := Disruptor[T]{
+		next:    -1,
+		entries: make([]T, 1<<order),
+		mask:    1<<order - 1,
+		order:   order,
+		ws:      ws,
+	}
+	d.cursor.Store(-1)
+	d.gating.Store(-1)
+	d.consumers.Store(&[]*DisruptorConsumer[T]{})
+
+	return &d
+}
+
+// NewConsumer creates a new consumer which only observes the entries
+// processed by all deps. It starts at the current cursor, or at the lowest
+// sequence of deps, so it does not wait for entries published before it
+func (d *Disruptor[T]) NewConsumer(deps ...*DisruptorConsumer[T]) *DisruptorConsumer[T] {
+	for _, dep := range deps {
+		if dep.d != d {
+			panic("bad dependency")
+		}
+	}
+	c := &DisruptorConsumer[T]{d: d, deps: slices.Clone(deps)}
+	c.seq.Store(d.start(deps))
+	for {
+		old := d.consumers.Load()
+		cs := append(slices.Clone(*old), c)
+		if d.consumers.CompareAndSwap(old, &cs) {
+			break
+		}
+	}
+	// producers may have claimed entries before they gate on c,
+	// so c starts after the cursor observed once it gates them
+	c.seq.Store(d.start(deps))
+
+	return c
+}
+
+// Next claims the next n sequences and returns the highest of them,
+// it waits while the consumers have not processed the entries to be overwritten
+func (d *Disruptor[T]) Next(n int) (hi int64) {
+	sw := SpinWait{}
+	for {
+		hi, ok := d.claim(n)
+		if ok {
+			return hi
+		}
+		d.ws.Wait(&sw)
+	}
+}
+
+// TryNext claims the next n sequences and returns the highest of them.
+// if the ring does not have n free entries, ErrTemporaryUnavailable will be returned
+func (d *Disruptor[T]) TryNext(n int) (hi int64, err error) {
+	hi, ok := d.claim(n)
+	if !ok {
+		return -1, ErrTemporaryUnavailable
+	}
+
+	return hi, nil
+}
+
+// Get returns the entry of the given sequence
+func (d *Disruptor[T]) Get(seq int64) *T {
+	return &d.entries[seq&d.mask]
+}
+
+// Publish makes the entries of sequences from lo to hi visible to consumers
+func (d *Disruptor[T]) Publish(lo, hi int64) {
+	if d.published == nil {
+		d.cursor.Store(hi)
+		return
+	}
+	for seq := lo; seq <= hi; seq++ {
+		d.published[seq&d.mask].Store(seq >> d.order)
+	}
+}
+
+// Cap returns the capacity of the ring
+func (d *Disruptor[T]) Cap() int {
+	return len(d.entries)
+}
+
+func (d *Disruptor[T]) claim(n int) (hi int64, ok bool) {
+	if n < 1 || n > len(d.entries) {
+		panic("bad count")
+	}
+	if d.published == nil {
+		hi = d.next + int64(n)
+		if !d.gate(hi, d.next) {
+			return -1, false
+		}
+		d.next = hi
+		return hi, true
+	}
+	for {
+		cur := d.cursor.Load()
+		hi = cur + int64(n)
+		if !d.gate(hi, cur) {
+			return -1, false
+		}
+		if d.cursor.CompareAndSwap(cur, hi) {
+			return hi, true
+		}
+	}
+}
+
+// gate reports whether all consumers have processed
+// the entries which sequence hi overwrites
+func (d *Disruptor[T]) gate(hi, cur int64) bool {
+	wrap := hi - int64(len(d.entries))
+	if wrap <= d.gating.Load() {
+		return true
+	}
+	seq := cur
+	for _, c := range *d.consumers.Load() {
+		seq = min(seq, c.seq.Load())
+	}
+	d.gating.Store(seq)
+
+	return wrap <= seq
+}
+
+// start returns the sequence which a new consumer
+// with the given dependencies has processed
+func (d *Disruptor[T]) start(deps []*DisruptorConsumer[T]) int64 {
+	seq := d.cursor.Load()
+	for _, dep := range deps {
+		seq = min(seq, dep.seq.Load())
+	}
+
+	return seq
+}
+
+// highestPublished returns the highest sequence from lo on
+// such that all sequences up to it are published
+func (d *Disruptor[T]) highestPublished(lo int64) int64 {
+	hi := d.cursor.Load()
+	if d.published == nil {
+		return hi
+	}
+	for seq := lo; seq <= hi; seq++ {
+		if d.published[seq&d.mask].Load() != seq>>d.order {
+			return seq - 1
+		}
+	}
+
+	return hi
+}
+
+// DisruptorConsumer represents a consumer of a Disruptor with its own sequence.
+// Each consumer must only be used by one goroutine at a time
+type DisruptorConsumer[T any] struct {
+	_    cpu.CacheLinePad
+	seq  atomic.Int64
+	_    cpu.CacheLinePad
+	d    *Disruptor[T]
+	deps []*DisruptorConsumer[T]
+}
+
+// Sequence returns the highest processed sequence
+func (c *DisruptorConsumer[T]) Sequence() int64 {
+	return c.seq.Load()
+}
+
+// Available returns the highest sequence which is ready to be processed
+func (c *DisruptorConsumer[T]) Available() int64 {
+	hi := c.d.highestPublished(c.seq.Load() + 1)
+	for _, dep := range c.deps {
+		hi = min(hi, dep.seq.Load())
+	}
+
+	return hi
+}
+
+// WaitFor waits until seq is ready to be processed
+// and returns the highest sequence ready to be processed
+func (c *DisruptorConsumer[T]) WaitFor(seq int64) int64 {
+	sw := SpinWait{}
+	for {
+		hi := c.Available()
+		if hi >= seq {
+			return hi
+		}
+		c.d.ws.Wait(&sw)
+	}
+}
+
+// TryWaitFor returns the highest sequence ready to be processed.
+// if seq is not ready yet, ErrTemporaryUnavailable will be returned
+func (c *DisruptorConsumer[T]) TryWaitFor(seq int64) (int64, error) {
+	hi := c.Available()
+	if hi < seq {
+		return hi, ErrTemporaryUnavailable
+	}
+
+	return hi, nil
+}
+
+// Commit marks all sequences up to seq as processed,
+// their entries may be overwritten once all consumers have processed them
+func (c *DisruptorConsumer[T]) Commit(seq int64) {
+	c.seq.Store(seq)
+}
+
+// Consume waits for the next entry, calls handler for every entry
+// ready to be processed in order, commits them and returns the highest
+// processed sequence
+func (c *DisruptorConsumer[T]) Consume(handler func(elem *T, seq int64)) int64 {
+	lo := c.seq.Load() + 1
+	hi := c.WaitFor(lo)
+	for seq := lo; seq <= hi; seq++ {
+		handler(c.d.Get(seq), seq)
+	}
+	c.Commit(hi)
+
+	return hi
+}
+
+// Len returns the approximate number of entries
+// which the consumer has not processed yet
+func (c *DisruptorConsumer[T]) Len() int {
+	return clampLen(c.d.cursor.Load()-c.seq.Load(), math.MaxInt)
+}
@@ -0,0 +1,188 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package concurrent_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"code.hybscloud.com/concurrent"
+)
+
+type disruptorEvent struct {
+	value   int64
+	doubled int64
+}
+
+func TestDisruptor(t *testing.T) {
+	t.Run("try next", func(t *testing.T) {
+		d := concurrent.NewDisruptorSingleProducer[int64](4, nil)
+		c := d.NewConsumer()
+		for i := range 4 {
+			hi, err := d.TryNext(1)
+			if err != nil || hi != int64(i) {
+				t.Errorf("try next expected %v, <nil> but got %v, %v", i, hi, err)
+				return
+			}
+			*d.Get(hi) = int64(i)
+			d.Publish(hi, hi)
+		}
+		_, err := d.TryNext(1)
+		if err != concurrent.ErrTemporaryUnavailable {
+			t.Errorf("try next expected ErrTemporaryUnavailable but got %v", err)
+			return
+		}
+		hi, err := c.TryWaitFor(0)
+		if err != nil || hi != 3 {
+			t.Errorf("try wait for expected 3, <nil> but got %v, %v", hi, err)
+			return
+		}
+		c.Commit(1)
+		hi, err = d.TryNext(2)
+		if err != nil || hi != 5 {
+			t.Errorf("try next expected 5, <nil> but got %v, %v", hi, err)
+			return
+		}
+		expectPanic(t, "bad count", func() { d.Next(5) })
+	})
+
+	t.Run("late consumer", func(t *testing.T) {
+		d := concurrent.NewDisruptorSingleProducer[int64](4, nil)
+		a := d.NewConsumer()
+		for i := range 4 {
+			hi := d.Next(1)
+			*d.Get(hi) = int64(i)
+			d.Publish(hi, hi)
+		}
+		a.Commit(3)
+		b := d.NewConsumer(a)
+		c := d.NewConsumer()
+		if b.Sequence() != 3 || c.Sequence() != 3 {
+			t.Errorf("late consumers expected sequence 3, 3 but got %v, %v", b.Sequence(), c.Sequence())
+			return
+		}
+		for i := 4; i < 8; i++ {
+			hi, err := d.TryNext(1)
+			if err != nil || hi != int64(i) {
+				t.Errorf("try next expected %v, <nil> but got %v, %v", i, hi, err)
+				return
+			}
+			*d.Get(hi) = int64(i)
+			d.Publish(hi, hi)
+		}
+		hi, err := c.TryWaitFor(4)
+		if err != nil || hi != 7 {
+			t.Errorf("try wait for expected 7, <nil> but got %v, %v", hi, err)
+			return
+		}
+		_, err = b.TryWaitFor(4)
+		if err != concurrent.ErrTemporaryUnavailable {
+			t.Errorf("try wait for expected ErrTemporaryUnavailable but got %v", err)
+			return
+		}
+	})
+
+	strategies := []struct {
+		name string
+		ws   concurrent.WaitStrategy
+	}{
+		{"spin", concurrent.SpinWaitStrategy{}},
+		{"yield", concurrent.YieldWaitStrategy{}},
+		{"sleep", concurrent.YieldWaitStrategy{Level: 1}},
+	}
+	for _, s := range strategies {
+		for _, pn := range []int{0, 1, 4} {
+			name := fmt.Sprintf("%s %d producers", s.name, pn)
+			if pn == 0 {
+				name = fmt.Sprintf("%s single producer", s.name)
+			}
+			t.Run(name, func(t *testing.T) {
+				testDisruptor(t, 1<<12, pn, s.ws)
+			})
+		}
+	}
+}
+
+func testDisruptor(t *testing.T, n int, pn int, ws concurrent.WaitStrategy) {
+	d := concurrent.NewDisruptor[disruptorEvent](64, ws)
+	if pn == 0 {
+		d = concurrent.NewDisruptorSingleProducer[disruptorEvent](64, ws)
+	}
+	a := d.NewConsumer()
+	b := d.NewConsumer()
+	c := d.NewConsumer(a, b)
+	total := int64(n * max(pn, 1))
+
+	wg := sync.WaitGroup{}
+	sums := make([]int64, 3)
+	for i, consumer := range []*concurrent.DisruptorConsumer[disruptorEvent]{a, b, c} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for consumer.Sequence() < total-1 {
+				consumer.Consume(func(elem *disruptorEvent, seq int64) {
+					switch i {
+					case 0:
+						elem.doubled = 2 * elem.value
+					case 1:
+						sums[i] += elem.value
+					case 2:
+						if elem.doubled != 2*elem.value {
+							t.Errorf("event %v expected doubled %v but got %v", seq, 2*elem.value, elem.doubled)
+						}
+						sums[i] += elem.doubled
+					}
+				})
+			}
+		}()
+	}
+
+	pwg := sync.WaitGroup{}
+	for range max(pn, 1) {
+		pwg.Add(1)
+		go func() {
+			defer pwg.Done()
+			for j := 0; j < n; {
+				k := min(1+j%3, n-j)
+				hi := d.Next(k)
+				lo := hi - int64(k) + 1
+				for seq := lo; seq <= hi; seq++ {
+					*d.Get(seq) = disruptorEvent{value: int64(j)}
+					j++
+				}
+				d.Publish(lo, hi)
+			}
+		}()
+	}
+	pwg.Wait()
+	wg.Wait()
+
+	expected := int64(max(pn, 1) * n * (n - 1) / 2)
+	if sums[1] != expected || sums[2] != 2*expected {
+		t.Errorf("sums expected %v, %v but got %v, %v", expected, 2*expected, sums[1], sums[2])
+	}
+}
+
+func BenchmarkDisruptor(b *testing.B) {
+	d := concurrent.NewDisruptorSingleProducer[int64](1<<10, nil)
+	cs := []*concurrent.DisruptorConsumer[int64]{d.NewConsumer(), d.NewConsumer(), d.NewConsumer()}
+	wg := sync.WaitGroup{}
+	for _, c := range cs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c.Sequence() < int64(b.N)-1 {
+				c.Consume(func(*int64, int64) {})
+			}
+		}()
+	}
+	for i := 0; i < b.N; i++ {
+		seq := d.Next(1)
+		*d.Get(seq) = int64(i)
+		d.Publish(seq, seq)
+	}
+	wg.Wait()
+}
@@ -13,31 +13,98 @@ var (
 	// ErrTemporaryUnavailable is the error used for Enqueue operations
 	// on a fulled queue or Dequeue operations on an empty queue
 	ErrTemporaryUnavailable = errors.New("temporary unavailable")
+	// ErrClosed is the error used for Enqueue operations on a closed queue
+	// or Dequeue operations on a closed and drained queue
+	ErrClosed = errors.New("queue closed")
+	// ErrAborted is the error used for Steal operations which lost
+	// the race for the top item, the caller may retry
+	ErrAborted = errors.New("aborted")
+	// ErrBadIndex is the error used for Enqueue operations of
+	// elements with the reserved top bit on MPMCQueueIndirect
+	ErrBadIndex = errors.New("bad index")
 )
 
 // QueueOptions is a struct that contains options for creating a queue.
 type QueueOptions struct {
-	SingleProducer bool // TODO: implement
-	SingleConsumer bool // TODO: implement
-	LowContention  bool // TODO: implement
-	DistinctValues bool
+	SingleProducer bool // only one goroutine enqueues at a time
+	SingleConsumer bool // only one goroutine dequeues at a time
+	LowContention  bool // only a few producers and consumers are active at a time
+	WaitFree       bool // every operation completes in a bounded number of steps
+	DistinctValues bool // no pointer is enqueued again while it is still queued
 }
 
 var defaultQueueOptions = QueueOptions{
 	DistinctValues: true,
 }
 
+// WithSingleProducer returns an option which declares that
+// the queue has only one producer
+func WithSingleProducer() func(opts *QueueOptions) {
+	return func(opts *QueueOptions) {
+		opts.SingleProducer = true
+	}
+}
+
+// WithSingleConsumer returns an option which declares that
+// the queue has only one consumer
+func WithSingleConsumer() func(opts *QueueOptions) {
+	return func(opts *QueueOptions) {
+		opts.SingleConsumer = true
+	}
+}
+
+// WithLowContention returns an option which declares that
+// only a few producers and consumers are active at the same time.
+// The queue optimized for it needs double word CAS, on platforms
+// other than amd64 and arm64 NewQueue ignores the option
+func WithLowContention() func(opts *QueueOptions) {
+	return func(opts *QueueOptions) {
+		opts.LowContention = true
+	}
+}
+
+// WithWaitFree returns an option which requires every Enqueue and Dequeue
+// to complete in a bounded number of steps.
+// The wait-free queue needs double word CAS, on platforms other than
+// amd64 and arm64 NewQueue ignores the option
+func WithWaitFree() func(opts *QueueOptions) {
+	return func(opts *QueueOptions) {
+		opts.WaitFree = true
+	}
+}
+
+// WithDistinctValues returns an option which declares whether
+// every enqueued value is distinct from the values still in the queue.
+// Without distinct values NewQueue chooses NewMPMCQueueNonDistinct
+// for capacities up to 1<<24, which needs no double word CAS
+func WithDistinctValues(distinct bool) func(opts *QueueOptions) {
+	return func(opts *QueueOptions) {
+		opts.DistinctValues = distinct
+	}
+}
+
 // NewQueue creates a new queue with the given capacity and options.
+// It chooses the implementation fitting the options best
 func NewQueue[T any](capacity int, opts ...func(opts *QueueOptions)) (Consumer[T], Producer[T]) {
 	opt := defaultQueueOptions
 	for o := range slices.Values(opts) {
 		o(&opt)
 	}
-	if !opt.DistinctValues {
-		panic("not implement")
+	switch {
+	case opt.SingleProducer && opt.SingleConsumer:
+		return NewSPSCQueue[T](capacity)
+	case opt.SingleConsumer:
+		return NewMPSCQueue[T](capacity)
+	case opt.WaitFree && hasCAS128:
+		return NewMPMCQueueWCQ[T](capacity)
+	case opt.SingleProducer:
+		return NewSPMCQueue[T](capacity)
+	case opt.LowContention && hasCAS128:
+		return NewMPMCQueueNBLFQ[T](capacity)
+	case !opt.DistinctValues && capacityOrder(capacity) <= scqHalfMaxOrder:
+		return NewMPMCQueueNonDistinct[T](capacity)
 	}
-	c, p := NewMPMCQueue[T](capacity)
-	return c, p
+	return NewMPMCQueue[T](capacity)
 }
 
 // Producer is the interface that wraps the Enqueue method
@@ -54,6 +121,39 @@ type Consumer[T any] interface {
 	Dequeue() (elem *T, err error)
 }
 
+// ProducerValue is the interface that wraps the Enqueue method of queues
+// which store the items by value
+type ProducerValue[T any] interface {
+	// Enqueue pushes a copy of item to FIFO queue.
+	// if the queue is fulled, ErrTemporaryUnavailable will be returned
+	Enqueue(elem T) error
+}
+
+// ConsumerValue is the interface that wraps the Dequeue method of queues
+// which store the items by value
+type ConsumerValue[T any] interface {
+	// Dequeue pops items from the FIFO queue.
+	// if the queue is empty, ErrTemporaryUnavailable will be returned
+	Dequeue() (elem T, err error)
+}
+
+// ProducerBatch is the interface that wraps the EnqueueBatch method.
+// Use a type assertion on a Producer to detect the support
+type ProducerBatch[T any] interface {
+	// EnqueueBatch pushes items to FIFO queue in order.
+	// if the queue is fulled before all items are pushed,
+	// the number of pushed items and ErrTemporaryUnavailable will be returned
+	EnqueueBatch(elems []*T) (n int, err error)
+}
+
+// ConsumerBatch is the interface that wraps the DequeueBatch method.
+// Use a type assertion on a Consumer to detect the support
+type ConsumerBatch[T any] interface {
+	// DequeueBatch pops up to len(elems) items from FIFO queue into elems.
+	// if the queue is empty, ErrTemporaryUnavailable will be returned
+	DequeueBatch(elems []*T) (n int, err error)
+}
+
 // ProducerIndirect is a non-generic producer interface that enqueues uintptr values.
 // Use this for indirect references such as indices, handles, or other integer-like identifiers.
 type ProducerIndirect interface {
@@ -66,9 +166,39 @@ type ConsumerIndirect interface {
 	Dequeue() (elem uintptr, err error)
 }
 
-// Closer is the interface that wraps the Close method
+// ProducerIndirectBatch is the batch counterpart of ProducerIndirect
+type ProducerIndirectBatch interface {
+	EnqueueBatch(elems []uintptr) (n int, err error)
+}
+
+// ConsumerIndirectBatch is the batch counterpart of ConsumerIndirect
+type ConsumerIndirectBatch interface {
+	DequeueBatch(elems []uintptr) (n int, err error)
+}
+
+// Sizer is the interface that wraps the methods reporting the size of a queue.
+// All queues in this package implement it.
+// Len, IsEmpty and IsFull are approximate under concurrent operations
+type Sizer interface {
+	// Len returns the number of queued items
+	Len() int
+	// Cap returns the number of items the queue can hold,
+	// which is the capacity rounded up to a power of 2
+	Cap() int
+	// IsEmpty reports whether the queue holds no items
+	IsEmpty() bool
+	// IsFull reports whether Enqueue would fail for lack of room
+	IsFull() bool
+}
+
+// Closer is the interface that wraps the Close method.
+// All queues in this package implement it
 type Closer interface {
 	// Close closes the queue.
-	// Enqueue and Dequeue operations on a closed queue are undefined
+	// Further Enqueue operations return ErrClosed, Dequeue operations
+	// keep draining the remaining items and then return ErrClosed.
+	// An Enqueue racing with Close may either succeed or return ErrClosed,
+	// Dequeue does not return ErrClosed before the items of all
+	// succeeded Enqueue operations are drained
 	Close() error
 }
@@ -6,5 +6,8 @@
 
 package concurrent
 
+// hasCAS128 reports whether cas128 is implemented on the platform
+const hasCAS128 = true
+
 //go:noescape
 func cas128(ptr *uint64, old, new [2]uint64) bool
@@ -6,6 +6,9 @@
 
 package concurrent
 
+// hasCAS128 reports whether cas128 is implemented on the platform
+const hasCAS128 = false
+
 func cas128(ptr *uint64, old, new [2]uint64) bool {
 	panic("not implemented")
 }
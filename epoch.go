@@ -0,0 +1,179 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package concurrent
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/sys/cpu"
+)
+
+// EpochDomain represents a domain of epoch-based memory reclamation.
+// Goroutines pin the domain while they access shared nodes, retired nodes
+// are reclaimed once every goroutine pinned at their retirement has unpinned.
+// A pinned goroutine which stalls delays reclamation of the whole domain
+type EpochDomain struct {
+	epoch   atomic.Uint64
+	_       cpu.CacheLinePad
+	records atomic.Pointer[epochRecord]
+	pool    sync.Pool
+	mu      sync.Mutex
+	garbage []epochBag
+}
+
+// NewEpochDomain creates a new epoch-based reclamation domain
+func NewEpochDomain() *EpochDomain {
+	return &EpochDomain{}
+}
+
+var defaultEpochDomain = NewEpochDomain()
+
+// Pin pins the default epoch domain, the returned Guard must be unpinned
+func Pin() Guard {
+	return defaultEpochDomain.Pin()
+}
+
+// Guard represents a pinned participant of an epoch domain.
+// Each Guard must only be used by one goroutine at a time
+type Guard struct {
+	r *epochRecord
+}
+
+// Pin pins the domain, nodes loaded from shared memory until the returned Guard
+// is unpinned will not be reclaimed
+func (d *EpochDomain) Pin() Guard {
+	r := d.acquire()
+	r.state.Store(d.epoch.Load()<<1 | epochPinned)
+
+	return Guard{r: r}
+}
+
+// Unpin unpins the domain, g must not be used afterward.
+// It flushes the functions retired by g once 16 of them
+// are pending, fewer of them wait in the record of g until a later Guard
+// flushes it. Call Flush before Unpin to hand them over right away
+func (g Guard) Unpin() {
+	r := g.r
+	r.state.Store(0)
+	if len(r.bag.fns) >= epochUnpinFlush {
+		g.Flush()
+	}
+	r.inUse.Store(false)
+	r.d.pool.Put(r)
+}
+
+// Retire defers f until no goroutine can hold a reference to the node
+// unlinked before the call, f usually recycles the node into a pool
+func (g Guard) Retire(f func()) {
+	r := g.r
+	r.bag.epoch = r.d.epoch.Load()
+	r.bag.fns = append(r.bag.fns, f)
+	if len(r.bag.fns) >= epochBagSize {
+		g.Flush()
+	}
+}
+
+// Flush hands over the functions retired by g to the domain
+// and runs those which are safe to run
+func (g Guard) Flush() {
+	r := g.r
+	if len(r.bag.fns) > 0 {
+		r.d.mu.Lock()
+		r.d.garbage = append(r.d.garbage, r.bag)
+		r.d.mu.Unlock()
+		r.bag = epochBag{}
+	}
+	r.d.Collect()
+}
+
+// Advance advances the global epoch if every pinned goroutine
+// has observed the current epoch and reports whether it has advanced
+func (d *EpochDomain) Advance() bool {
+	e := d.epoch.Load()
+	for r := d.records.Load(); r != nil; r = r.next {
+		s := r.state.Load()
+		if s&epochPinned != 0 && s>>1 != e {
+			return false
+		}
+	}
+
+	return d.epoch.CompareAndSwap(e, e+1)
+}
+
+// Collect tries to advance the global epoch and runs the retired functions
+// which are safe to run. Functions still held by guards are not collected
+func (d *EpochDomain) Collect() {
+	d.Advance()
+	e := d.epoch.Load()
+	var ready []epochBag
+	d.mu.Lock()
+	garbage := d.garbage[:0]
+	for _, b := range d.garbage {
+		if b.epoch+2 <= e {
+			ready = append(ready, b)
+		} else {
+			garbage = append(garbage, b)
+		}
+	}
+	clear(d.garbage[len(garbage):])
+	d.garbage = garbage
+	d.mu.Unlock()
+	for _, b := range ready {
+		for _, f := range b.fns {
+			f()
+		}
+	}
+}
+
+// Epoch returns the global epoch
+func (d *EpochDomain) Epoch() uint64 {
+	return d.epoch.Load()
+}
+
+const (
+	epochPinned     = 1
+	epochBagSize    = 64
+	epochUnpinFlush = 16
+)
+
+// participant of an epoch domain
+//
+// Records are linked once and never removed, a record is owned by the Guard
+// which set inUse. The pool only caches idle records
+type epochRecord struct {
+	_     cpu.CacheLinePad
+	state atomic.Uint64 // epoch<<1 | epochPinned while pinned
+	inUse atomic.Bool
+	_     cpu.CacheLinePad
+	next  *epochRecord
+	d     *EpochDomain
+	bag   epochBag
+}
+
+// functions retired not later than epoch
+type epochBag struct {
+	epoch uint64
+	fns   []func()
+}
+
+func (d *EpochDomain) acquire() *epochRecord {
+	if r, ok := d.pool.Get().(*epochRecord); ok && r.inUse.CompareAndSwap(false, true) {
+		return r
+	}
+	for r := d.records.Load(); r != nil; r = r.next {
+		if !r.inUse.Load() && r.inUse.CompareAndSwap(false, true) {
+			return r
+		}
+	}
+	r := &epochRecord{d: d}
+	r.inUse.Store(true)
+	for {
+		r.next = d.records.Load()
+		if d.records.CompareAndSwap(r.next, r) {
+			return r
+		}
+	}
+}
@@ -0,0 +1,138 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package concurrent_test
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"code.hybscloud.com/concurrent"
+)
+
+func TestEpochDomain(t *testing.T) {
+	t.Run("advance", func(t *testing.T) {
+		d := concurrent.NewEpochDomain()
+		g := d.Pin()
+		if !d.Advance() {
+			t.Error("advance expected true with a guard pinned at the current epoch")
+			return
+		}
+		if d.Advance() {
+			t.Error("advance expected false with a guard pinned at the previous epoch")
+			return
+		}
+		g.Unpin()
+		if !d.Advance() {
+			t.Error("advance expected true after unpin")
+		}
+	})
+
+	t.Run("retire", func(t *testing.T) {
+		d := concurrent.NewEpochDomain()
+		reader := d.Pin()
+		g := d.Pin()
+		retired := atomic.Bool{}
+		g.Retire(func() { retired.Store(true) })
+		g.Flush()
+		g.Unpin()
+		for range 4 {
+			d.Collect()
+		}
+		if retired.Load() {
+			t.Error("retired function expected to wait for the pinned reader")
+			return
+		}
+		reader.Unpin()
+		for range 4 {
+			d.Collect()
+		}
+		if !retired.Load() {
+			t.Error("retired function expected to run after the reader unpinned")
+		}
+	})
+
+	t.Run("flush on unpin", func(t *testing.T) {
+		d := concurrent.NewEpochDomain()
+		g := d.Pin()
+		retired := atomic.Int32{}
+		for range 16 {
+			g.Retire(func() { retired.Add(1) })
+		}
+		g.Unpin()
+		for range 4 {
+			d.Collect()
+		}
+		if retired.Load() != 16 {
+			t.Errorf("retired functions expected to run 16 times after unpin but ran %v times", retired.Load())
+		}
+	})
+
+	for _, n := range []int{2, 4, 16} {
+		t.Run(fmt.Sprintf("recycle nodes with %d goroutines", n), func(t *testing.T) {
+			testReclaimStructure(t, n, newEpochStack())
+		})
+	}
+}
+
+// epochStack is a Treiber stack protected by an epoch domain,
+// it pins a guard per operation and needs no handle per goroutine
+type epochStack struct {
+	reclaimFreeList
+	d    *concurrent.EpochDomain
+	head atomic.Pointer[reclaimNode]
+}
+
+func newEpochStack() *epochStack {
+	return &epochStack{reclaimFreeList: newReclaimFreeList(), d: concurrent.NewEpochDomain()}
+}
+
+func (s *epochStack) acquire() struct{} {
+	return struct{}{}
+}
+
+func (s *epochStack) release(struct{}) {}
+
+func (s *epochStack) push(_ struct{}, v int64) {
+	g := s.d.Pin()
+	defer g.Unpin()
+	n := s.node(v)
+	for {
+		h := s.head.Load()
+		n.next.Store(h)
+		if s.head.CompareAndSwap(h, n) {
+			return
+		}
+	}
+}
+
+func (s *epochStack) pop(t *testing.T, _ struct{}) (int64, bool) {
+	g := s.d.Pin()
+	defer g.Unpin()
+	for {
+		h := s.head.Load()
+		if h == nil {
+			return 0, false
+		}
+		next := h.next.Load()
+		expectLive(t, h)
+		if s.head.CompareAndSwap(h, next) {
+			v := h.value.Load()
+			g.Retire(func() { s.reclaim(h) })
+			return v, true
+		}
+	}
+}
+
+func BenchmarkEpochDomain(b *testing.B) {
+	d := concurrent.NewEpochDomain()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			g := d.Pin()
+			g.Retire(func() {})
+			g.Unpin()
+		}
+	})
+}
@@ -6,6 +6,8 @@
 
 package concurrent
 
+import "sync/atomic"
+
 func or64(ptr *uint64, val uint64) {
-	*ptr |= val
+	atomic.OrUint64(ptr, val)
 }
@@ -5,145 +5,1241 @@
 package concurrent
 
 import (
-	"unsafe"
+	"context"
+	"math"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sys/cpu"
 )
 
-// SPSCQueue represents simple producer single consumer FIFO queue
-type SPSCQueue[T any] struct{}
+// SPSCQueue represents simple producer single consumer FIFO queue.
+// It is a wait-free Lamport ring with cached indices,
+// both sides use plain atomic loads and stores only
+type SPSCQueue[T any] struct {
+	closer
+	entries    []*T
+	mask       uint64
+	_          cpu.CacheLinePad
+	head       atomic.Uint64
+	cachedTail uint64
+	_          cpu.CacheLinePad
+	tail       atomic.Uint64
+	claimed    atomic.Uint64
+	cachedHead uint64
+	_          cpu.CacheLinePad
+}
 
 // NewSPSCQueue creates a new simple producer single consumer
 // FIFO queue with the given capacity
 func NewSPSCQueue[T any](capacity int) (Consumer[T], Producer[T]) {
-	panic("not implement")
+	order := capacityOrder(capacity)
+	if order > 30 {
+		panic("bad capacity order")
+	}
+	q := SPSCQueue[T]{
+		entries: make([]*T, 1<<order),
+		mask:    1<<order - 1,
+	}
+
+	return &q, &q
 }
 
-// MPSCQueue represents multiple producers single consumer FIFO queue
-type MPSCQueue[T any] struct{}
+// Enqueue pushes the given item to a FIFO queue.
+// It must be called from a single producer
+func (q *SPSCQueue[T]) Enqueue(elem *T) error {
+	t := q.tail.Load()
+	if t-q.cachedHead > q.mask {
+		q.cachedHead = q.head.Load()
+		if t-q.cachedHead > q.mask {
+			if q.closed.Load() {
+				return ErrClosed
+			}
+			return ErrTemporaryUnavailable
+		}
+	}
+	if !q.claim(&q.claimed, t+1) {
+		return ErrClosed
+	}
+	q.entries[t&q.mask] = elem
+	q.tail.Store(t + 1)
+	q.publish()
+
+	return nil
+}
+
+// Dequeue pops items from FIFO queue.
+// It must be called from a single consumer
+func (q *SPSCQueue[T]) Dequeue() (elem *T, err error) {
+	closed := q.sealedAt(&q.claimed, &q.tail)
+	h := q.head.Load()
+	if h == q.cachedTail {
+		q.cachedTail = q.tail.Load()
+		if h == q.cachedTail {
+			return elem, drained(closed)
+		}
+	}
+	elem = q.entries[h&q.mask]
+	q.entries[h&q.mask] = nil
+	q.head.Store(h + 1)
+	q.notFull.notify()
+
+	return
+}
+
+// Len returns the approximate number of queued elements
+func (q *SPSCQueue[T]) Len() int {
+	return clampLen(int64(q.tail.Load()-q.head.Load()), int(q.mask+1))
+}
+
+// Cap returns the capacity of the queue
+func (q *SPSCQueue[T]) Cap() int {
+	return int(q.mask + 1)
+}
+
+// IsEmpty reports whether the queue is approximately empty
+func (q *SPSCQueue[T]) IsEmpty() bool {
+	return int64(q.tail.Load()-q.head.Load()) <= 0
+}
+
+// IsFull reports whether the queue is approximately full
+func (q *SPSCQueue[T]) IsFull() bool {
+	return int64(q.tail.Load()-q.head.Load()) >= int64(int(q.mask+1))
+}
+
+// MPSCQueue represents multiple producers single consumer FIFO queue.
+// Producers reserve room and claim positions with fetch-and-add,
+// the consumer uses atomic loads and stores only
+type MPSCQueue[T any] struct {
+	mpCloser
+	entries  []mpscEntry[T]
+	mask     uint64
+	_        cpu.CacheLinePad
+	head     atomic.Uint64
+	_        cpu.CacheLinePad
+	reserved atomic.Uint64
+	_        cpu.CacheLinePad
+	tail     atomic.Uint64
+	_        cpu.CacheLinePad
+}
+
+type mpscEntry[T any] struct {
+	seq  atomic.Uint64
+	elem *T
+}
 
 // NewMPSCQueue creates a new multiple producers single consumer
 // FIFO queue with the given capacity
 func NewMPSCQueue[T any](capacity int) (Consumer[T], Producer[T]) {
-	panic("not implement")
+	order := capacityOrder(capacity)
+	if order > 30 {
+		panic("bad capacity order")
+	}
+	q := MPSCQueue[T]{
+		entries: make([]mpscEntry[T], 1<<order),
+		mask:    1<<order - 1,
+	}
+
+	return &q, &q
+}
+
+// Enqueue pushes the given item to a FIFO queue
+func (q *MPSCQueue[T]) Enqueue(elem *T) error {
+	pending := q.enter()
+	if pending == nil {
+		return ErrClosed
+	}
+	// every claimed position is covered by a reservation taken before it,
+	// so the entry at the claimed position has always been consumed
+	r := q.reserved.Add(1)
+	if r-q.head.Load() > q.mask+1 {
+		q.reserved.Add(^uint64(0))
+		q.leave(pending)
+		return ErrTemporaryUnavailable
+	}
+	t := q.tail.Add(1) - 1
+	e := &q.entries[t&q.mask]
+	e.elem = elem
+	e.seq.Store(t + 1)
+	q.leave(pending)
+	q.notEmpty.notify()
+
+	return nil
+}
+
+// Dequeue pops items from FIFO queue.
+// It must be called from a single consumer
+func (q *MPSCQueue[T]) Dequeue() (elem *T, err error) {
+	closed := q.sealed()
+	h := q.head.Load()
+	e := &q.entries[h&q.mask]
+	if e.seq.Load() != h+1 {
+		return elem, drained(closed)
+	}
+	elem = e.elem
+	e.elem = nil
+	q.head.Store(h + 1)
+	q.notFull.notify()
+
+	return
+}
+
+// Len returns the approximate number of queued elements
+func (q *MPSCQueue[T]) Len() int {
+	return clampLen(int64(q.tail.Load()-q.head.Load()), int(q.mask+1))
+}
+
+// Cap returns the capacity of the queue
+func (q *MPSCQueue[T]) Cap() int {
+	return int(q.mask + 1)
+}
+
+// IsEmpty reports whether the queue is approximately empty
+func (q *MPSCQueue[T]) IsEmpty() bool {
+	return int64(q.tail.Load()-q.head.Load()) <= 0
 }
 
-// SPMCQueue represents single producer multiple consumers FIFO queue
-type SPMCQueue[T any] struct{}
+// IsFull reports whether the queue is approximately full
+func (q *MPSCQueue[T]) IsFull() bool {
+	return int64(q.tail.Load()-q.head.Load()) >= int64(int(q.mask+1))
+}
+
+// SPMCQueue represents single producer multiple consumers FIFO queue.
+// The producer publishes entries with atomic stores only,
+// consumers claim entries with a single CAS on the head
+type SPMCQueue[T any] struct {
+	closer
+	entries []spmcEntry[T]
+	mask    uint64
+	_       cpu.CacheLinePad
+	head    atomic.Uint64
+	_       cpu.CacheLinePad
+	tail    atomic.Uint64
+	claimed atomic.Uint64
+	_       cpu.CacheLinePad
+}
+
+type spmcEntry[T any] struct {
+	seq  atomic.Uint64
+	elem atomic.Pointer[T]
+}
 
 // NewSPMCQueue creates a new single producer multiple consumers
 // FIFO queue with the given capacity
 func NewSPMCQueue[T any](capacity int) (Consumer[T], Producer[T]) {
-	panic("not implement")
+	order := capacityOrder(capacity)
+	if order > 30 {
+		panic("bad capacity order")
+	}
+	q := SPMCQueue[T]{
+		entries: make([]spmcEntry[T], 1<<order),
+		mask:    1<<order - 1,
+	}
+	for i := range q.entries {
+		q.entries[i].seq.Store(uint64(i))
+	}
+
+	return &q, &q
 }
 
-// MPMCQueue represents multiple producers multiple consumers FIFO queue
+// Enqueue pushes the given item to a FIFO queue.
+// It must be called from a single producer
+func (q *SPMCQueue[T]) Enqueue(elem *T) error {
+	t := q.tail.Load()
+	e := &q.entries[t&q.mask]
+	if e.seq.Load() != t {
+		if q.closed.Load() {
+			return ErrClosed
+		}
+		return ErrTemporaryUnavailable
+	}
+	if !q.claim(&q.claimed, t+1) {
+		return ErrClosed
+	}
+	e.elem.Store(elem)
+	e.seq.Store(t + 1)
+	q.tail.Store(t + 1)
+	q.publish()
+
+	return nil
+}
+
+// Dequeue pops items from FIFO queue
+func (q *SPMCQueue[T]) Dequeue() (elem *T, err error) {
+	closed := q.sealedAt(&q.claimed, &q.tail)
+	for {
+		h := q.head.Load()
+		e := &q.entries[h&q.mask]
+		seq := e.seq.Load()
+		if seq != h+1 {
+			if int64(seq-(h+1)) < 0 {
+				return elem, drained(closed)
+			}
+			continue
+		}
+		// the entry may be released and reused as soon as another
+		// consumer wins, so the element is loaded atomically
+		elem = e.elem.Load()
+		if q.head.CompareAndSwap(h, h+1) {
+			e.elem.Store(nil)
+			e.seq.Store(h + q.mask + 1)
+			q.notFull.notify()
+			return elem, nil
+		}
+	}
+}
+
+// Len returns the approximate number of queued elements
+func (q *SPMCQueue[T]) Len() int {
+	return clampLen(int64(q.tail.Load()-q.head.Load()), int(q.mask+1))
+}
+
+// Cap returns the capacity of the queue
+func (q *SPMCQueue[T]) Cap() int {
+	return int(q.mask + 1)
+}
+
+// IsEmpty reports whether the queue is approximately empty
+func (q *SPMCQueue[T]) IsEmpty() bool {
+	return int64(q.tail.Load()-q.head.Load()) <= 0
+}
+
+// IsFull reports whether the queue is approximately full
+func (q *SPMCQueue[T]) IsFull() bool {
+	return int64(q.tail.Load()-q.head.Load()) >= int64(int(q.mask+1))
+}
+
+// MPMCQueue represents multiple producers multiple consumers FIFO queue.
+// Enqueued items stay reachable for the GC until they are dequeued
 type MPMCQueue[T any] struct {
-	*rmfLF
+	*slots[T, rmfLFIndex]
+	mpCloser
 }
 
 // NewMPMCQueue creates a new multiple producers multiple consumers
-// FIFO queue with the given capacity
+// FIFO queue with the given capacity.
+// It accepts the same pointer several times
 func NewMPMCQueue[T any](capacity int) (Consumer[T], Producer[T]) {
-	if capacity < 2 {
-		panic("bad capacity")
-	}
-	capacity--
-	order := 0
-	for capacity > 0 {
-		order++
-		capacity >>= 1
-	}
-	q := MPMCQueue[T]{rmfLF: newRmfLF(order)}
+	order := capacityOrder(capacity)
+	q := MPMCQueue[T]{slots: &slots[T, rmfLFIndex]{}}
+	q.init(newRmfLFIndex(order), newRmfLFIndex(order))
 
 	return &q, &q
 }
 
 // Enqueue pushes the given item to a FIFO queue
 func (q *MPMCQueue[T]) Enqueue(elem *T) error {
-	ok := q.offer(uintptr(unsafe.Pointer(elem)))
+	pending := q.enter()
+	if pending == nil {
+		return ErrClosed
+	}
+	ok := q.offer(elem)
+	q.leave(pending)
 	if !ok {
 		return ErrTemporaryUnavailable
 	}
+	q.notEmpty.notify()
 
 	return nil
 }
 
 // Dequeue pops items from FIFO queue
 func (q *MPMCQueue[T]) Dequeue() (elem *T, err error) {
-	ptr, ok := q.poll()
+	closed := q.sealed()
+	elem, ok := q.poll()
 	if !ok {
-		return elem, ErrTemporaryUnavailable
+		return elem, drained(closed)
 	}
-	elem = (*T)(unsafe.Pointer(ptr))
+	q.notFull.notify()
 
 	return
 }
 
+// EnqueueBatch pushes the given items to a FIFO queue in order.
+// It returns the number of pushed items, err is not nil
+// if only the first n items have been pushed
+func (q *MPMCQueue[T]) EnqueueBatch(elems []*T) (n int, err error) {
+	pending := q.enter()
+	if pending == nil {
+		return 0, ErrClosed
+	}
+	indices := [mpmcQueueBatch]uintptr{}
+	for n < len(elems) {
+		k := q.fq.pollBatch(indices[:min(len(indices), len(elems)-n)])
+		if k == 0 {
+			break
+		}
+		for j, i := range indices[:k] {
+			q.entries[i].Store(elems[n+j])
+		}
+		m := q.aq.offerBatch(indices[:k])
+		for _, i := range indices[m:k] {
+			q.entries[i].Store(nil)
+			q.fq.offer(uint64(i))
+		}
+		n += m
+		if m < k {
+			break
+		}
+	}
+	q.leave(pending)
+	for range n {
+		q.notEmpty.notify()
+	}
+	if n < len(elems) {
+		return n, ErrTemporaryUnavailable
+	}
+
+	return n, nil
+}
+
+// DequeueBatch pops up to len(elems) items from FIFO queue into elems.
+// It returns the number of popped items, err is not nil
+// only if the queue is empty
+func (q *MPMCQueue[T]) DequeueBatch(elems []*T) (n int, err error) {
+	closed := q.sealed()
+	indices := [mpmcQueueBatch]uintptr{}
+	for n < len(elems) {
+		k := q.aq.pollBatch(indices[:min(len(indices), len(elems)-n)])
+		if k == 0 {
+			break
+		}
+		for j, i := range indices[:k] {
+			elems[n+j] = q.entries[i].Swap(nil)
+		}
+		// the free ring always has room for the indices taken out of it
+		for m := 0; m < k; {
+			m += q.fq.offerBatch(indices[m:k])
+		}
+		n += k
+	}
+	if n == 0 && len(elems) > 0 {
+		return 0, drained(closed)
+	}
+	for range n {
+		q.notFull.notify()
+	}
+
+	return n, nil
+}
+
+// mpmcQueueBatch is the number of slot indices moved at once by batch operations
+const mpmcQueueBatch = 64
+
 // MPMCQueueIndirect represents multiple producers multiple consumers FIFO queue
 // with indirect references
 type MPMCQueueIndirect struct {
 	*rmfLF
+	mpCloser
 }
 
 // NewMPMCQueueIndirect creates a new multiple producers multiple consumers
-// FIFO queue with the given capacity
+// FIFO queue with the given capacity.
+// The top bit of the elements is reserved for empty entries,
+// Enqueue rejects elements with it set with ErrBadIndex.
+// Use NewMPMCQueueIndirectSCQ for the full uintptr range
 func NewMPMCQueueIndirect(capacity int) (ConsumerIndirect, ProducerIndirect) {
-	if capacity < 2 {
-		panic("bad capacity")
-	}
-	capacity--
-	order := 0
-	for capacity > 0 {
-		order++
-		capacity >>= 1
-	}
+	order := capacityOrder(capacity)
 	q := MPMCQueueIndirect{rmfLF: newRmfLF(order)}
 
 	return &q, &q
 }
 
 func (q *MPMCQueueIndirect) Enqueue(elem uintptr) error {
+	if elem&rmfLFNilFlag != 0 {
+		return ErrBadIndex
+	}
+	pending := q.enter()
+	if pending == nil {
+		return ErrClosed
+	}
 	ok := q.offer(elem)
+	q.leave(pending)
 	if !ok {
 		return ErrTemporaryUnavailable
 	}
+	q.notEmpty.notify()
 
 	return nil
 }
 
 func (q *MPMCQueueIndirect) Dequeue() (elem uintptr, err error) {
+	closed := q.sealed()
 	ptr, ok := q.poll()
 	if !ok {
-		return elem, ErrTemporaryUnavailable
+		return elem, drained(closed)
 	}
 	elem = ptr
+	q.notFull.notify()
 
 	return
 }
 
-// EnqueueWait pushes the given item to a fifo queue.
-// the operation will block until a success or error occurred
-func EnqueueWait[T any](p Producer[T], elem *T) error {
+// EnqueueBatch pushes the given items to a FIFO queue in order.
+// It returns the number of pushed items, err is not nil
+// if only the first n items have been pushed.
+// The items in front of the first one with the reserved top bit are pushed,
+// err is ErrBadIndex if all of them have been pushed
+func (q *MPMCQueueIndirect) EnqueueBatch(elems []uintptr) (n int, err error) {
+	k := slices.IndexFunc(elems, func(elem uintptr) bool { return elem&rmfLFNilFlag != 0 })
+	if k < 0 {
+		k = len(elems)
+	}
+	pending := q.enter()
+	if pending == nil {
+		return 0, ErrClosed
+	}
+	n = q.offerBatch(elems[:k])
+	q.leave(pending)
+	for range n {
+		q.notEmpty.notify()
+	}
+	if n < k {
+		return n, ErrTemporaryUnavailable
+	}
+	if k < len(elems) {
+		return n, ErrBadIndex
+	}
+
+	return n, nil
+}
+
+// DequeueBatch pops up to len(elems) items from FIFO queue into elems.
+// It returns the number of popped items, err is not nil
+// only if the queue is empty
+func (q *MPMCQueueIndirect) DequeueBatch(elems []uintptr) (n int, err error) {
+	closed := q.sealed()
+	n = q.pollBatch(elems)
+	if n == 0 && len(elems) > 0 {
+		return 0, drained(closed)
+	}
+	for range n {
+		q.notFull.notify()
+	}
+
+	return n, nil
+}
+
+// MPMCQueueIndirectSCQ represents multiple producers multiple consumers FIFO queue
+// with indirect references based on the sCQ algorithm.
+// Elements are kept in a slot array whose indices circulate
+// through two sCQ rings, so it accepts any uintptr
+type MPMCQueueIndirectSCQ struct {
+	entries []atomic.Uintptr
+	aq, fq  *scq
+	mpCloser
+}
+
+// NewMPMCQueueIndirectSCQ creates a new multiple producers multiple consumers
+// FIFO queue with the given capacity which accepts the full uintptr range.
+// It requires double word CAS
+func NewMPMCQueueIndirectSCQ(capacity int) (ConsumerIndirect, ProducerIndirect) {
+	order := capacityOrder(capacity)
+	q := MPMCQueueIndirectSCQ{
+		entries: make([]atomic.Uintptr, 1<<order),
+		aq:      newSCQ(order),
+		fq:      newSCQ(order),
+	}
+	for i := range uint64(len(q.entries)) {
+		q.fq.offer(i)
+	}
+
+	return &q, &q
+}
+
+func (q *MPMCQueueIndirectSCQ) Enqueue(elem uintptr) error {
+	pending := q.enter()
+	if pending == nil {
+		return ErrClosed
+	}
+	i, ok := q.fq.poll()
+	if !ok {
+		q.leave(pending)
+		return ErrTemporaryUnavailable
+	}
+	q.entries[i].Store(elem)
+	q.aq.offer(i)
+	q.leave(pending)
+	q.notEmpty.notify()
+
+	return nil
+}
+
+func (q *MPMCQueueIndirectSCQ) Dequeue() (elem uintptr, err error) {
+	closed := q.sealed()
+	i, ok := q.aq.poll()
+	if !ok {
+		return elem, drained(closed)
+	}
+	elem = q.entries[i].Load()
+	q.fq.offer(i)
+	q.notFull.notify()
+
+	return
+}
+
+// Len returns the approximate number of queued elements
+func (q *MPMCQueueIndirectSCQ) Len() int {
+	return q.aq.Len()
+}
+
+// Cap returns the capacity of the queue
+func (q *MPMCQueueIndirectSCQ) Cap() int {
+	return len(q.entries)
+}
+
+// IsEmpty reports whether the queue is approximately empty
+func (q *MPMCQueueIndirectSCQ) IsEmpty() bool {
+	return q.aq.IsEmpty()
+}
+
+// IsFull reports whether the queue is approximately full
+func (q *MPMCQueueIndirectSCQ) IsFull() bool {
+	return q.aq.IsFull()
+}
+
+// MPMCQueueSCQ represents multiple producers multiple consumers FIFO queue
+// based on the scalable circular queue (sCQ) algorithm
+type MPMCQueueSCQ[T any] struct {
+	*slots[T, *scq]
+	mpCloser
+}
+
+// NewMPMCQueueSCQ creates a new multiple producers multiple consumers
+// FIFO queue with the given capacity based on the sCQ algorithm.
+// It requires double word CAS and scales better than NewMPMCQueue
+// under heavy producer and consumer contention
+func NewMPMCQueueSCQ[T any](capacity int) (Consumer[T], Producer[T]) {
+	order := capacityOrder(capacity)
+	q := MPMCQueueSCQ[T]{slots: &slots[T, *scq]{}}
+	q.init(newSCQ(order), newSCQ(order))
+
+	return &q, &q
+}
+
+// Enqueue pushes the given item to a FIFO queue
+func (q *MPMCQueueSCQ[T]) Enqueue(elem *T) error {
+	pending := q.enter()
+	if pending == nil {
+		return ErrClosed
+	}
+	ok := q.offer(elem)
+	q.leave(pending)
+	if !ok {
+		return ErrTemporaryUnavailable
+	}
+	q.notEmpty.notify()
+
+	return nil
+}
+
+// Dequeue pops items from FIFO queue
+func (q *MPMCQueueSCQ[T]) Dequeue() (elem *T, err error) {
+	closed := q.sealed()
+	elem, ok := q.poll()
+	if !ok {
+		return elem, drained(closed)
+	}
+	q.notFull.notify()
+
+	return
+}
+
+// MPMCQueueWCQ represents multiple producers multiple consumers FIFO queue
+// based on the wait-free circular queue (wCQ) algorithm
+type MPMCQueueWCQ[T any] struct {
+	*slots[T, *wcq]
+	mpCloser
+}
+
+// NewMPMCQueueWCQ creates a new multiple producers multiple consumers
+// FIFO queue with the given capacity based on the wCQ algorithm.
+// Every Enqueue and Dequeue completes in a bounded number of steps
+// as long as GOMAXPROCS is not raised after the queue has been created,
+// otherwise operations on the added Ps are lock-free only
+func NewMPMCQueueWCQ[T any](capacity int) (Consumer[T], Producer[T]) {
+	order := capacityOrder(capacity)
+	q := MPMCQueueWCQ[T]{slots: &slots[T, *wcq]{}}
+	q.init(newWCQ(order), newWCQ(order))
+
+	return &q, &q
+}
+
+// Enqueue pushes the given item to a FIFO queue
+func (q *MPMCQueueWCQ[T]) Enqueue(elem *T) error {
+	pending := q.enter()
+	if pending == nil {
+		return ErrClosed
+	}
+	ok := q.offer(elem)
+	q.leave(pending)
+	if !ok {
+		return ErrTemporaryUnavailable
+	}
+	q.notEmpty.notify()
+
+	return nil
+}
+
+// Dequeue pops items from FIFO queue
+func (q *MPMCQueueWCQ[T]) Dequeue() (elem *T, err error) {
+	closed := q.sealed()
+	elem, ok := q.poll()
+	if !ok {
+		return elem, drained(closed)
+	}
+	q.notFull.notify()
+
+	return
+}
+
+// MPMCQueueLSCQ represents unbounded multiple producers multiple consumers
+// FIFO queue built from linked sCQ rings (LSCQ)
+type MPMCQueueLSCQ[T any] struct {
+	*lscq[T]
+	mpCloser
+}
+
+// NewMPMCQueueLSCQ creates a new unbounded multiple producers multiple consumers
+// FIFO queue which links a new ring of the given capacity whenever the tail ring is full.
+// Enqueue never returns ErrTemporaryUnavailable
+func NewMPMCQueueLSCQ[T any](segmentCapacity int) (Consumer[T], Producer[T]) {
+	order := capacityOrder(segmentCapacity)
+	q := MPMCQueueLSCQ[T]{lscq: newLSCQ[T](order)}
+
+	return &q, &q
+}
+
+// Enqueue pushes the given item to a FIFO queue
+func (q *MPMCQueueLSCQ[T]) Enqueue(elem *T) error {
+	pending := q.enter()
+	if pending == nil {
+		return ErrClosed
+	}
+	q.offer(elem)
+	q.leave(pending)
+	q.notEmpty.notify()
+
+	return nil
+}
+
+// Dequeue pops items from FIFO queue
+func (q *MPMCQueueLSCQ[T]) Dequeue() (elem *T, err error) {
+	closed := q.sealed()
+	elem, ok := q.poll()
+	if !ok {
+		return elem, drained(closed)
+	}
+	q.notFull.notify()
+
+	return
+}
+
+// Len returns the approximate number of queued elements
+func (q *MPMCQueueLSCQ[T]) Len() int {
+	n := 0
+	for r := q.head.Load(); r != nil; r = r.next.Load() {
+		n += r.aq.Len()
+	}
+
+	return n
+}
+
+// Cap returns math.MaxInt as the queue is unbounded
+func (q *MPMCQueueLSCQ[T]) Cap() int {
+	return math.MaxInt
+}
+
+// IsEmpty reports whether the queue is approximately empty
+func (q *MPMCQueueLSCQ[T]) IsEmpty() bool {
+	return q.Len() == 0
+}
+
+// IsFull reports false as the queue is unbounded
+func (q *MPMCQueueLSCQ[T]) IsFull() bool {
+	return false
+}
+
+// MPMCQueueNBLFQ represents multiple producers multiple consumers FIFO queue
+// optimized for low contention, following the NBLFQ approach
+type MPMCQueueNBLFQ[T any] struct {
+	*slots[T, *nblfq]
+	mpCloser
+}
+
+// NewMPMCQueueNBLFQ creates a new multiple producers multiple consumers
+// FIFO queue with the given capacity which is optimized for
+// the case that only a few producers and consumers are active at the same time.
+// It also accepts the same pointer several times
+func NewMPMCQueueNBLFQ[T any](capacity int) (Consumer[T], Producer[T]) {
+	order := capacityOrder(capacity)
+	q := MPMCQueueNBLFQ[T]{slots: &slots[T, *nblfq]{}}
+	q.init(newNBLFQ(order), newNBLFQ(order))
+
+	return &q, &q
+}
+
+// Enqueue pushes the given item to a FIFO queue
+func (q *MPMCQueueNBLFQ[T]) Enqueue(elem *T) error {
+	pending := q.enter()
+	if pending == nil {
+		return ErrClosed
+	}
+	ok := q.offer(elem)
+	q.leave(pending)
+	if !ok {
+		return ErrTemporaryUnavailable
+	}
+	q.notEmpty.notify()
+
+	return nil
+}
+
+// Dequeue pops items from FIFO queue
+func (q *MPMCQueueNBLFQ[T]) Dequeue() (elem *T, err error) {
+	closed := q.sealed()
+	elem, ok := q.poll()
+	if !ok {
+		return elem, drained(closed)
+	}
+	q.notFull.notify()
+
+	return
+}
+
+// MPMCQueueNonDistinct represents multiple producers multiple consumers FIFO queue
+// which accepts the same pointer several times.
+// Elements are kept in a slot array, the indices of allocated slots and
+// free slots circulate through two half-word sCQ rings
+type MPMCQueueNonDistinct[T any] struct {
+	entries []*T
+	aq, fq  *scqHalf
+	mpCloser
+}
+
+// NewMPMCQueueNonDistinct creates a new multiple producers multiple consumers
+// FIFO queue with the given capacity which tolerates duplicated values.
+// The capacity must not be greater than 1<<24
+func NewMPMCQueueNonDistinct[T any](capacity int) (Consumer[T], Producer[T]) {
+	order := capacityOrder(capacity)
+	q := MPMCQueueNonDistinct[T]{
+		entries: make([]*T, 1<<order),
+		aq:      newSCQHalf(order),
+		fq:      newSCQHalf(order),
+	}
+	for i := range uint32(len(q.entries)) {
+		q.fq.offer(i)
+	}
+
+	return &q, &q
+}
+
+// Enqueue pushes the given item to a FIFO queue
+func (q *MPMCQueueNonDistinct[T]) Enqueue(elem *T) error {
+	pending := q.enter()
+	if pending == nil {
+		return ErrClosed
+	}
+	i, ok := q.fq.poll()
+	if !ok {
+		q.leave(pending)
+		return ErrTemporaryUnavailable
+	}
+	q.entries[i] = elem
+	q.aq.offer(i)
+	q.leave(pending)
+	q.notEmpty.notify()
+
+	return nil
+}
+
+// Dequeue pops items from FIFO queue
+func (q *MPMCQueueNonDistinct[T]) Dequeue() (elem *T, err error) {
+	closed := q.sealed()
+	i, ok := q.aq.poll()
+	if !ok {
+		return elem, drained(closed)
+	}
+	elem = q.entries[i]
+	q.entries[i] = nil
+	q.fq.offer(i)
+	q.notFull.notify()
+
+	return
+}
+
+// Len returns the approximate number of queued elements
+func (q *MPMCQueueNonDistinct[T]) Len() int {
+	return q.aq.Len()
+}
+
+// Cap returns the capacity of the queue
+func (q *MPMCQueueNonDistinct[T]) Cap() int {
+	return len(q.entries)
+}
+
+// IsEmpty reports whether the queue is approximately empty
+func (q *MPMCQueueNonDistinct[T]) IsEmpty() bool {
+	return q.aq.IsEmpty()
+}
+
+// IsFull reports whether the queue is approximately full
+func (q *MPMCQueueNonDistinct[T]) IsFull() bool {
+	return q.aq.IsFull()
+}
+
+// MPMCQueueValue represents multiple producers multiple consumers FIFO queue
+// which stores the items by value. It is a Vyukov ring with sequence numbered slots,
+// a Dequeue may report an empty queue while a preempted Enqueue is filling
+// the next slot even though later slots are already filled
+type MPMCQueueValue[T any] struct {
+	mpCloser
+	entries []valueEntry[T]
+	mask    uint64
+	_       cpu.CacheLinePad
+	head    atomic.Uint64
+	_       cpu.CacheLinePad
+	tail    atomic.Uint64
+	_       cpu.CacheLinePad
+}
+
+type valueEntry[T any] struct {
+	seq  atomic.Uint64
+	elem T
+}
+
+// NewMPMCQueueValue creates a new multiple producers multiple consumers
+// FIFO queue of values with the given capacity.
+// Values are copied in and out, so the same value may be queued several times
+func NewMPMCQueueValue[T any](capacity int) (ConsumerValue[T], ProducerValue[T]) {
+	order := capacityOrder(capacity)
+	if order > 30 {
+		panic("bad capacity order")
+	}
+	q := MPMCQueueValue[T]{
+		entries: make([]valueEntry[T], 1<<order),
+		mask:    1<<order - 1,
+	}
+	for i := range q.entries {
+		q.entries[i].seq.Store(uint64(i))
+	}
+
+	return &q, &q
+}
+
+// Enqueue pushes the given item to a FIFO queue
+func (q *MPMCQueueValue[T]) Enqueue(elem T) error {
+	pending := q.enter()
+	if pending == nil {
+		return ErrClosed
+	}
 	for {
-		err := p.Enqueue(elem)
-		if err == ErrTemporaryUnavailable {
-			Yield()
+		t := q.tail.Load()
+		e := &q.entries[t&q.mask]
+		seq := e.seq.Load()
+		if seq != t {
+			if int64(seq-t) < 0 {
+				q.leave(pending)
+				return ErrTemporaryUnavailable
+			}
 			continue
 		}
+		if q.tail.CompareAndSwap(t, t+1) {
+			e.elem = elem
+			e.seq.Store(t + 1)
+			break
+		}
+	}
+	q.leave(pending)
+	q.notEmpty.notify()
 
-		return err
+	return nil
+}
+
+// Dequeue pops items from FIFO queue
+func (q *MPMCQueueValue[T]) Dequeue() (elem T, err error) {
+	closed := q.sealed()
+	for {
+		h := q.head.Load()
+		e := &q.entries[h&q.mask]
+		seq := e.seq.Load()
+		if seq != h+1 {
+			if int64(seq-(h+1)) < 0 {
+				return elem, drained(closed)
+			}
+			continue
+		}
+		if q.head.CompareAndSwap(h, h+1) {
+			var zero T
+			elem, e.elem = e.elem, zero
+			e.seq.Store(h + q.mask + 1)
+			break
+		}
 	}
+	q.notFull.notify()
+
+	return
+}
+
+// Len returns the approximate number of queued elements
+func (q *MPMCQueueValue[T]) Len() int {
+	return clampLen(int64(q.tail.Load()-q.head.Load()), int(q.mask+1))
+}
+
+// Cap returns the capacity of the queue
+func (q *MPMCQueueValue[T]) Cap() int {
+	return int(q.mask + 1)
+}
+
+// IsEmpty reports whether the queue is approximately empty
+func (q *MPMCQueueValue[T]) IsEmpty() bool {
+	return int64(q.tail.Load()-q.head.Load()) <= 0
+}
+
+// IsFull reports whether the queue is approximately full
+func (q *MPMCQueueValue[T]) IsFull() bool {
+	// the head never passes the tail, so the head is loaded first
+	h := q.head.Load()
+	return q.tail.Load()-h > q.mask
+}
+
+// EnqueueWait pushes the given item to a fifo queue.
+// the operation will block until a success or error occurred,
+// it returns ErrClosed once the queue is closed
+func EnqueueWait[T any](p Producer[T], elem *T) error {
+	return EnqueueWaitContext(context.Background(), p, elem)
 }
 
 // DequeueWait pops items from fifo queue.
-// the operation will block until a success or error occurred
+// the operation will block until a success or error occurred,
+// it returns ErrClosed once the queue is closed and drained
 func DequeueWait[T any](c Consumer[T]) (elem *T, err error) {
+	return DequeueWaitContext(context.Background(), c)
+}
+
+// EnqueueWaitContext pushes the given item to a fifo queue.
+// the operation will block until a success or error occurred,
+// it returns ctx.Err() once the context is done.
+// Queues of this package park the caller until a Dequeue makes room,
+// other producers are polled with Yield
+func EnqueueWaitContext[T any](ctx context.Context, p Producer[T], elem *T) (err error) {
+	err = p.Enqueue(elem)
+	if err != ErrTemporaryUnavailable {
+		return
+	}
+	if q, ok := p.(parker); ok {
+		werr := q.parking().notFull.wait(ctx, func() bool {
+			err = p.Enqueue(elem)
+			return err != ErrTemporaryUnavailable
+		})
+		if werr != nil {
+			return werr
+		}
+		return
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		Yield()
+		err = p.Enqueue(elem)
+		if err != ErrTemporaryUnavailable {
+			return
+		}
+	}
+}
+
+// DequeueWaitContext pops items from fifo queue.
+// the operation will block until a success or error occurred,
+// it returns ctx.Err() once the context is done.
+// Queues of this package park the caller until an Enqueue arrives,
+// other consumers are polled with Yield
+func DequeueWaitContext[T any](ctx context.Context, c Consumer[T]) (elem *T, err error) {
+	elem, err = c.Dequeue()
+	if err != ErrTemporaryUnavailable {
+		return
+	}
+	if q, ok := c.(parker); ok {
+		werr := q.parking().notEmpty.wait(ctx, func() bool {
+			elem, err = c.Dequeue()
+			return err != ErrTemporaryUnavailable
+		})
+		if werr != nil {
+			return nil, werr
+		}
+		return
+	}
 	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+		Yield()
 		elem, err = c.Dequeue()
-		if err == ErrTemporaryUnavailable {
-			Yield()
-			continue
+		if err != ErrTemporaryUnavailable {
+			return
 		}
+	}
+}
+
+// EnqueueWaitTimeout pushes the given item to a fifo queue.
+// the operation will block until a success or error occurred,
+// it returns context.DeadlineExceeded once the timeout elapses
+func EnqueueWaitTimeout[T any](p Producer[T], elem *T, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
 
+	return EnqueueWaitContext(ctx, p, elem)
+}
+
+// DequeueWaitTimeout pops items from fifo queue.
+// the operation will block until a success or error occurred,
+// it returns context.DeadlineExceeded once the timeout elapses
+func DequeueWaitTimeout[T any](c Consumer[T], timeout time.Duration) (elem *T, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return DequeueWaitContext(ctx, c)
+}
+
+// closer implements Closer for the queues.
+// An Enqueue announces itself before it loads the closed flag and Close
+// stores the flag before it wakes consumers up, so either the Enqueue
+// observes the flag and fails, or a Dequeue which observes the flag also
+// observes the Enqueue in flight. Dequeue checks the sealed state before
+// polling, an empty result after observing the sealed state means every
+// item of an accepted Enqueue is drained
+type closer struct {
+	closed atomic.Bool
+	notifier
+}
+
+// Close closes the queue, further Enqueue operations return ErrClosed
+// and Dequeue returns ErrClosed once the remaining items are drained.
+// It returns ErrClosed if the queue is already closed
+func (c *closer) Close() error {
+	if c.closed.Swap(true) {
+		return ErrClosed
+	}
+	c.notEmpty.broadcast()
+	c.notFull.broadcast()
+
+	return nil
+}
+
+// claim announces the position a single producer is about to publish,
+// it reports false and withdraws the claim if the queue is closed
+func (c *closer) claim(claimed *atomic.Uint64, pos uint64) bool {
+	claimed.Store(pos)
+	if c.closed.Load() {
+		claimed.Store(pos - 1)
+		return false
+	}
+
+	return true
+}
+
+// publish wakes up a consumer after the producer published its item,
+// all of them once the queue is closed so they observe the sealed state
+func (c *closer) publish() {
+	if c.closed.Load() {
+		c.notEmpty.broadcast()
 		return
 	}
+	c.notEmpty.notify()
+}
+
+// sealedAt reports whether the queue is closed and a single producer
+// has published every position it claimed
+func (c *closer) sealedAt(claimed, published *atomic.Uint64) bool {
+	return c.closed.Load() && claimed.Load() == published.Load()
+}
+
+// mpCloser implements Closer for the queues with multiple producers.
+// It counts the Enqueue operations in flight on stripes picked by the P
+// of the producer, so producers on different Ps do not share a cache line,
+// and only sums the stripes once the queue is closed
+type mpCloser struct {
+	closer
+	inflight [mpCloserStripes]struct {
+		_ cpu.CacheLinePad
+		n atomic.Int64
+	}
+	_ cpu.CacheLinePad
+}
+
+const mpCloserStripes = 16
+
+// enter registers an Enqueue on the stripe of the current P and returns it,
+// it returns nil if the queue is closed
+func (c *mpCloser) enter() *atomic.Int64 {
+	pid := procPin()
+	procUnpin()
+	pending := &c.inflight[pid%mpCloserStripes].n
+	pending.Add(1)
+	if c.closed.Load() {
+		c.leave(pending)
+		return nil
+	}
+
+	return pending
+}
+
+// leave unregisters an Enqueue from the stripe returned by enter
+// after its item has been offered
+func (c *mpCloser) leave(pending *atomic.Int64) {
+	if pending.Add(-1) == 0 && c.closed.Load() {
+		// consumers waiting for the drained queue
+		// may see ErrClosed now
+		c.notEmpty.broadcast()
+	}
+}
+
+// sealed reports whether the queue is closed
+// and no accepted Enqueue is in flight
+func (c *mpCloser) sealed() bool {
+	if !c.closed.Load() {
+		return false
+	}
+	for i := range c.inflight {
+		if c.inflight[i].n.Load() != 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// drained returns the error for Dequeue on an empty queue
+func drained(closed bool) error {
+	if closed {
+		return ErrClosed
+	}
+
+	return ErrTemporaryUnavailable
+}
+
+// clampLen clamps the difference of racy counters into [0, capacity]
+func clampLen(n int64, capacity int) int {
+	return int(min(max(n, 0), int64(capacity)))
+}
+
+// capacityOrder returns the order of the smallest power of 2
+// that is greater than or equal to capacity
+func capacityOrder(capacity int) int {
+	if capacity < 2 {
+		panic("bad capacity")
+	}
+	capacity--
+	order := 0
+	for capacity > 0 {
+		order++
+		capacity >>= 1
+	}
+
+	return order
 }
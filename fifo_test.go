@@ -5,10 +5,14 @@
 package concurrent_test
 
 import (
+	"context"
+	"fmt"
 	"math"
+	"runtime"
 	"sync"
 	"sync/atomic"
 	"testing"
+	"time"
 
 	"code.hybscloud.com/concurrent"
 )
@@ -49,51 +53,236 @@ func TestQueue(t *testing.T) {
 			t.Errorf("Expected 100, got %d", *result)
 		}
 	})
-	t.Run("with invalid options", func(t *testing.T) {
-		defer func() {
-			if r := recover(); r == nil {
-				t.Error("Expected panic for invalid options")
+	t.Run("with option constructors", func(t *testing.T) {
+		opts := [][]func(*concurrent.QueueOptions){
+			{concurrent.WithSingleProducer(), concurrent.WithSingleConsumer()},
+			{concurrent.WithSingleProducer()},
+			{concurrent.WithSingleConsumer()},
+			{concurrent.WithLowContention()},
+			{concurrent.WithWaitFree()},
+			{concurrent.WithSingleProducer(), concurrent.WithWaitFree()},
+			{concurrent.WithSingleConsumer(), concurrent.WithDistinctValues(false)},
+		}
+		for _, o := range opts {
+			c, p := concurrent.NewQueue[int](16, o...)
+			vals := []int{100, 200}
+			for i := range vals {
+				err := concurrent.EnqueueWait(p, &vals[i])
+				if err != nil {
+					t.Errorf("Enqueue failed: %v", err)
+					return
+				}
 			}
-		}()
-		_, _ = concurrent.NewQueue[int](16, func(opts *concurrent.QueueOptions) {
-			opts.DistinctValues = false
-		})
+			for i := range vals {
+				result, err := concurrent.DequeueWait(c)
+				if err != nil {
+					t.Errorf("Dequeue failed: %v", err)
+					return
+				}
+				if *result != vals[i] {
+					t.Errorf("Expected %d, got %d", vals[i], *result)
+					return
+				}
+			}
+		}
+	})
+	t.Run("with non-distinct values", func(t *testing.T) {
+		c, p := concurrent.NewQueue[int](16, concurrent.WithDistinctValues(false))
+		if _, ok := p.(*concurrent.MPMCQueueNonDistinct[int]); !ok {
+			t.Errorf("new queue expected *MPMCQueueNonDistinct but got %T", p)
+			return
+		}
+		val := 100
+		for range 4 {
+			err := p.Enqueue(&val)
+			if err != nil {
+				t.Errorf("Enqueue failed: %v", err)
+				return
+			}
+		}
+		for range 4 {
+			result, err := c.Dequeue()
+			if err != nil {
+				t.Errorf("Dequeue failed: %v", err)
+				return
+			}
+			if result != &val {
+				t.Errorf("Expected %p, got %p", &val, result)
+				return
+			}
+		}
+		_, err := c.Dequeue()
+		if err != concurrent.ErrTemporaryUnavailable {
+			t.Errorf("dequeue expected ErrTemporaryUnavailable but got %v", err)
+		}
 	})
 }
 
 func TestSPSCQueue(t *testing.T) {
-	defer func() {
-		if r := recover(); r == nil {
-			t.Errorf("expected not implement panic")
+	t.Run("simple enqueue dequeue", func(t *testing.T) {
+		c, p := concurrent.NewSPSCQueue[int](4)
+		_, err := c.Dequeue()
+		if err != concurrent.ErrTemporaryUnavailable {
+			t.Errorf("dequeue expected ErrTemporaryUnavailable but got %v", err)
+			return
 		}
-	}()
-	c, p := concurrent.NewSPSCQueue[int](8)
-	if c == nil || p == nil {
-		t.Error("NewSPSCQueue returned nil")
-	}
+		items := []int{100, 101, 102, 103, 104}
+		for i := range 4 {
+			err = p.Enqueue(&items[i])
+			if err != nil {
+				t.Errorf("enqueue: %v", err)
+				return
+			}
+		}
+		err = p.Enqueue(&items[4]) // full
+		if err != concurrent.ErrTemporaryUnavailable {
+			t.Errorf("enqueue expected ErrTemporaryUnavailable but got %v", err)
+			return
+		}
+		for i := range 4 {
+			elem, err := c.Dequeue()
+			if err != nil {
+				t.Errorf("dequeue: %v", err)
+				return
+			}
+			if *elem != items[i] {
+				t.Errorf("dequeue expected %v but got %v", items[i], *elem)
+				return
+			}
+		}
+		_, err = c.Dequeue()
+		if err != concurrent.ErrTemporaryUnavailable {
+			t.Errorf("dequeue expected ErrTemporaryUnavailable but got %v", err)
+			return
+		}
+	})
+
+	t.Run("invalid capacity", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("Expected panic for capacity < 2")
+			}
+		}()
+		concurrent.NewSPSCQueue[int](1)
+	})
+
+	t.Run("1 consumer 1 producer", func(t *testing.T) {
+		c, p := concurrent.NewSPSCQueue[int64](1 << 8)
+		testMPMCQueue(t, c, p, 1, 1)
+	})
 }
 
 func TestMPSCQueue(t *testing.T) {
-	defer func() {
-		if r := recover(); r == nil {
-			t.Errorf("expected not implement panic")
+	t.Run("simple enqueue dequeue", func(t *testing.T) {
+		c, p := concurrent.NewMPSCQueue[int](4)
+		_, err := c.Dequeue()
+		if err != concurrent.ErrTemporaryUnavailable {
+			t.Errorf("dequeue expected ErrTemporaryUnavailable but got %v", err)
+			return
+		}
+		items := []int{100, 101, 102, 103, 104}
+		for i := range 4 {
+			err = p.Enqueue(&items[i])
+			if err != nil {
+				t.Errorf("enqueue: %v", err)
+				return
+			}
+		}
+		err = p.Enqueue(&items[4]) // full
+		if err != concurrent.ErrTemporaryUnavailable {
+			t.Errorf("enqueue expected ErrTemporaryUnavailable but got %v", err)
+			return
+		}
+		for i := range 4 {
+			elem, err := c.Dequeue()
+			if err != nil {
+				t.Errorf("dequeue: %v", err)
+				return
+			}
+			if *elem != items[i] {
+				t.Errorf("dequeue expected %v but got %v", items[i], *elem)
+				return
+			}
 		}
-	}()
-	c, p := concurrent.NewMPSCQueue[int](8)
-	if c == nil || p == nil {
-		t.Error("NewMPSCQueue returned nil")
+		_, err = c.Dequeue()
+		if err != concurrent.ErrTemporaryUnavailable {
+			t.Errorf("dequeue expected ErrTemporaryUnavailable but got %v", err)
+			return
+		}
+	})
+
+	t.Run("invalid capacity", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("Expected panic for capacity < 2")
+			}
+		}()
+		concurrent.NewMPSCQueue[int](1)
+	})
+
+	const defaultCapacity = 1 << 8
+	for _, pn := range []int{1, 4, 16, 64} {
+		t.Run(fmt.Sprintf("1 consumer %d producers", pn), func(t *testing.T) {
+			c, p := concurrent.NewMPSCQueue[int64](defaultCapacity)
+			testMPMCQueue(t, c, p, 1, pn)
+		})
 	}
 }
 
 func TestSPMCQueue(t *testing.T) {
-	defer func() {
-		if r := recover(); r == nil {
-			t.Errorf("expected not implement panic")
+	t.Run("simple enqueue dequeue", func(t *testing.T) {
+		c, p := concurrent.NewSPMCQueue[int](4)
+		_, err := c.Dequeue()
+		if err != concurrent.ErrTemporaryUnavailable {
+			t.Errorf("dequeue expected ErrTemporaryUnavailable but got %v", err)
+			return
+		}
+		items := []int{100, 101, 102, 103, 104}
+		for i := range 4 {
+			err = p.Enqueue(&items[i])
+			if err != nil {
+				t.Errorf("enqueue: %v", err)
+				return
+			}
+		}
+		err = p.Enqueue(&items[4]) // full
+		if err != concurrent.ErrTemporaryUnavailable {
+			t.Errorf("enqueue expected ErrTemporaryUnavailable but got %v", err)
+			return
+		}
+		for i := range 4 {
+			elem, err := c.Dequeue()
+			if err != nil {
+				t.Errorf("dequeue: %v", err)
+				return
+			}
+			if *elem != items[i] {
+				t.Errorf("dequeue expected %v but got %v", items[i], *elem)
+				return
+			}
+		}
+		_, err = c.Dequeue()
+		if err != concurrent.ErrTemporaryUnavailable {
+			t.Errorf("dequeue expected ErrTemporaryUnavailable but got %v", err)
+			return
 		}
-	}()
-	c, p := concurrent.NewSPMCQueue[int](8)
-	if c == nil || p == nil {
-		t.Error("NewSPMCQueue returned nil")
+	})
+
+	t.Run("invalid capacity", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("Expected panic for capacity < 2")
+			}
+		}()
+		concurrent.NewSPMCQueue[int](1)
+	})
+
+	const defaultCapacity = 1 << 8
+	for _, cn := range []int{1, 4, 16, 64} {
+		t.Run(fmt.Sprintf("%d consumers 1 producer", cn), func(t *testing.T) {
+			c, p := concurrent.NewSPMCQueue[int64](defaultCapacity)
+			testMPMCQueue(t, c, p, cn, 1)
+		})
 	}
 }
 
@@ -331,6 +520,263 @@ func TestMPMCQueueRmfLF(t *testing.T) {
 		c, p := concurrent.NewMPMCQueue[int64](defaultCapacity)
 		testMPMCQueue(t, c, p, 64, 64)
 	})
+
+	t.Run("same value concurrently", func(t *testing.T) {
+		const n, cn, pn = 1 << 10, 4, 4
+		c, p := concurrent.NewMPMCQueue[int](16)
+		val := 100
+		for range pn {
+			go func() {
+				for range n {
+					_ = concurrent.EnqueueWait(p, &val)
+				}
+			}()
+		}
+		wg := sync.WaitGroup{}
+		for range cn {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for range n * pn / cn {
+					elem, err := concurrent.DequeueWait(c)
+					if err != nil || elem != &val {
+						t.Errorf("dequeue expected %p but got %p, %v", &val, elem, err)
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		_, err := c.Dequeue()
+		if err != concurrent.ErrTemporaryUnavailable {
+			t.Errorf("dequeue expected ErrTemporaryUnavailable but got %v", err)
+		}
+	})
+}
+
+func TestMPMCQueueBatch(t *testing.T) {
+	t.Run("partial success", func(t *testing.T) {
+		c, p := concurrent.NewMPMCQueue[int](4)
+		pb, cb := p.(concurrent.ProducerBatch[int]), c.(concurrent.ConsumerBatch[int])
+		items := []int{100, 101, 102, 103, 104, 105}
+		elems := make([]*int, len(items))
+		for i := range items {
+			elems[i] = &items[i]
+		}
+		n, err := pb.EnqueueBatch(elems[:3])
+		if n != 3 || err != nil {
+			t.Errorf("enqueue batch expected 3, <nil> but got %v, %v", n, err)
+			return
+		}
+		n, err = pb.EnqueueBatch(elems[3:])
+		if n != 1 || err != concurrent.ErrTemporaryUnavailable {
+			t.Errorf("enqueue batch expected 1, ErrTemporaryUnavailable but got %v, %v", n, err)
+			return
+		}
+		out := make([]*int, 3)
+		n, err = cb.DequeueBatch(out)
+		if n != 3 || err != nil {
+			t.Errorf("dequeue batch expected 3, <nil> but got %v, %v", n, err)
+			return
+		}
+		for i := range n {
+			if *out[i] != items[i] {
+				t.Errorf("dequeue batch expected %v but got %v", items[i], *out[i])
+				return
+			}
+		}
+		n, err = cb.DequeueBatch(out)
+		if n != 1 || err != nil || *out[0] != items[3] {
+			t.Errorf("dequeue batch expected 1, <nil> but got %v, %v", n, err)
+			return
+		}
+		n, err = cb.DequeueBatch(out)
+		if n != 0 || err != concurrent.ErrTemporaryUnavailable {
+			t.Errorf("dequeue batch expected 0, ErrTemporaryUnavailable but got %v, %v", n, err)
+			return
+		}
+	})
+
+	t.Run("indirect", func(t *testing.T) {
+		c, p := concurrent.NewMPMCQueueIndirect(4)
+		pb, cb := p.(concurrent.ProducerIndirectBatch), c.(concurrent.ConsumerIndirectBatch)
+		n, err := pb.EnqueueBatch([]uintptr{1, 2, 3, 4, 5})
+		if n != 4 || err != concurrent.ErrTemporaryUnavailable {
+			t.Errorf("enqueue batch expected 4, ErrTemporaryUnavailable but got %v, %v", n, err)
+			return
+		}
+		out := make([]uintptr, 8)
+		n, err = cb.DequeueBatch(out)
+		if n != 4 || err != nil {
+			t.Errorf("dequeue batch expected 4, <nil> but got %v, %v", n, err)
+			return
+		}
+		for i := range n {
+			if out[i] != uintptr(i+1) {
+				t.Errorf("dequeue batch expected %v but got %v", i+1, out[i])
+				return
+			}
+		}
+	})
+
+	for _, cn := range []int{1, 4, 16} {
+		for _, pn := range []int{1, 4, 16} {
+			t.Run(fmt.Sprintf("%d consumers %d producers", cn, pn), func(t *testing.T) {
+				c, p := concurrent.NewMPMCQueue[int64](64)
+				testMPMCQueueBatch(t, c.(concurrent.ConsumerBatch[int64]), p.(concurrent.ProducerBatch[int64]), cn, pn)
+			})
+		}
+	}
+}
+
+func testMPMCQueueBatch(t *testing.T, c concurrent.ConsumerBatch[int64], p concurrent.ProducerBatch[int64], cn, pn int) {
+	const n, batch = 1 << 12, 16
+	items := make([]int64, pn*n)
+	for i := range pn {
+		go func() {
+			elems := make([]*int64, batch)
+			for j := 0; j < n; j += batch {
+				for k := range batch {
+					items[i*n+j+k] = int64(i<<32) | int64(j+k)
+					elems[k] = &items[i*n+j+k]
+				}
+				for pushed := 0; pushed < batch; {
+					m, err := p.EnqueueBatch(elems[pushed:])
+					if err != nil && err != concurrent.ErrTemporaryUnavailable {
+						t.Errorf("enqueue batch: %v", err)
+						return
+					}
+					pushed += m
+					runtime.Gosched()
+				}
+			}
+		}()
+	}
+	seen := make([]atomic.Bool, pn*n)
+	consumed := atomic.Int64{}
+	wg := sync.WaitGroup{}
+	for range cn {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			last := make([]int64, pn)
+			for i := range last {
+				last[i] = -1
+			}
+			elems := make([]*int64, batch)
+			for consumed.Load() < int64(pn*n) {
+				m, err := c.DequeueBatch(elems)
+				if err == concurrent.ErrTemporaryUnavailable {
+					runtime.Gosched()
+					continue
+				}
+				if err != nil {
+					t.Errorf("dequeue batch: %v", err)
+					return
+				}
+				for _, e := range elems[:m] {
+					high, low := *e>>32, *e&math.MaxUint32
+					if seen[high*n+low].Swap(true) {
+						t.Errorf("dequeue batch duplicated %d:%d", high, low)
+						return
+					}
+					if low <= last[high] {
+						t.Errorf("dequeue batch out of order %d:%d after %d", high, low, last[high])
+						return
+					}
+					last[high] = low
+				}
+				consumed.Add(int64(m))
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestMPMCQueueSCQ(t *testing.T) {
+	t.Run("simple enqueue dequeue", func(t *testing.T) {
+		c, p := concurrent.NewMPMCQueueSCQ[int](4)
+		_, err := c.Dequeue()
+		if err != concurrent.ErrTemporaryUnavailable {
+			t.Errorf("dequeue expected ErrTemporaryUnavailable but got %v", err)
+			return
+		}
+		items := []int{100, 101, 102, 103, 104}
+		for i := range 4 {
+			err = p.Enqueue(&items[i])
+			if err != nil {
+				t.Errorf("enqueue: %v", err)
+				return
+			}
+		}
+		err = p.Enqueue(&items[4]) // full
+		if err != concurrent.ErrTemporaryUnavailable {
+			t.Errorf("enqueue expected ErrTemporaryUnavailable but got %v", err)
+			return
+		}
+		for i := range 4 {
+			elem, err := c.Dequeue()
+			if err != nil {
+				t.Errorf("dequeue: %v", err)
+				return
+			}
+			if *elem != items[i] {
+				t.Errorf("dequeue expected %v but got %v", items[i], *elem)
+				return
+			}
+		}
+		_, err = c.Dequeue()
+		if err != concurrent.ErrTemporaryUnavailable {
+			t.Errorf("dequeue expected ErrTemporaryUnavailable but got %v", err)
+			return
+		}
+	})
+
+	t.Run("many rounds", func(t *testing.T) {
+		c, p := concurrent.NewMPMCQueueSCQ[int](8)
+		items := make([]int, 1<<10)
+		for i := range items {
+			items[i] = i
+			err := p.Enqueue(&items[i])
+			if err != nil {
+				t.Errorf("enqueue: %v", err)
+				return
+			}
+			if i%3 != 2 {
+				continue
+			}
+			for j := i - 2; j <= i; j++ {
+				elem, err := c.Dequeue()
+				if err != nil {
+					t.Errorf("dequeue: %v", err)
+					return
+				}
+				if *elem != j {
+					t.Errorf("dequeue expected %v but got %v", j, *elem)
+					return
+				}
+			}
+		}
+	})
+
+	t.Run("invalid capacity", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("Expected panic for capacity < 2")
+			}
+		}()
+		concurrent.NewMPMCQueueSCQ[int](1)
+	})
+
+	const defaultCapacity = 1 << 8
+	for _, cn := range []int{1, 4, 16, 64} {
+		for _, pn := range []int{1, 4, 16, 64} {
+			t.Run(fmt.Sprintf("%d consumers %d producers", cn, pn), func(t *testing.T) {
+				c, p := concurrent.NewMPMCQueueSCQ[int64](defaultCapacity)
+				testMPMCQueue(t, c, p, cn, pn)
+			})
+		}
+	}
 }
 
 func TestMPMCQueueIndirect(t *testing.T) {
@@ -371,6 +817,31 @@ func TestMPMCQueueIndirect(t *testing.T) {
 		}
 	})
 
+	t.Run("reserved top bit", func(t *testing.T) {
+		c, p := concurrent.NewMPMCQueueIndirect(4)
+		err := p.Enqueue(1<<63 | 1)
+		if err != concurrent.ErrBadIndex {
+			t.Errorf("enqueue expected ErrBadIndex but got %v", err)
+			return
+		}
+		n, err := p.(concurrent.ProducerIndirectBatch).EnqueueBatch([]uintptr{1, 2, 1<<63 | 3, 4})
+		if n != 2 || err != concurrent.ErrBadIndex {
+			t.Errorf("enqueue batch expected 2, ErrBadIndex but got %v, %v", n, err)
+			return
+		}
+		for _, expected := range []uintptr{1, 2} {
+			elem, err := c.Dequeue()
+			if err != nil || elem != expected {
+				t.Errorf("dequeue expected %v, <nil> but got %v, %v", expected, elem, err)
+				return
+			}
+		}
+		_, err = c.Dequeue()
+		if err != concurrent.ErrTemporaryUnavailable {
+			t.Errorf("dequeue expected ErrTemporaryUnavailable but got %v", err)
+		}
+	})
+
 	t.Run("invalid capacity", func(t *testing.T) {
 		defer func() {
 			if r := recover(); r == nil {
@@ -390,17 +861,469 @@ func TestMPMCQueueIndirect(t *testing.T) {
 	})
 }
 
-func BenchmarkMPMCQueueRmfLF(b *testing.B) {
-	const defaultCapacity = 1 << 16
-
-	b.Run("1 consumer 1 producer", func(b *testing.B) {
-		c, p := concurrent.NewMPMCQueue[int64](defaultCapacity)
-		benchmarkMPMCQueue(b, c, p, 1, 1)
+func TestMPMCQueueIndirectSCQ(t *testing.T) {
+	t.Run("full range", func(t *testing.T) {
+		c, p := concurrent.NewMPMCQueueIndirectSCQ(8)
+		values := []uintptr{0, 1, 1 << 63, 1<<63 | 1, math.MaxUint64, math.MaxUint64 - 1, 0, 1 << 63}
+		for _, v := range values {
+			err := p.Enqueue(v)
+			if err != nil {
+				t.Errorf("enqueue: %v", err)
+				return
+			}
+		}
+		err := p.Enqueue(42) // full
+		if err != concurrent.ErrTemporaryUnavailable {
+			t.Errorf("enqueue expected ErrTemporaryUnavailable but got %v", err)
+			return
+		}
+		for _, v := range values {
+			elem, err := c.Dequeue()
+			if err != nil {
+				t.Errorf("dequeue: %v", err)
+				return
+			}
+			if elem != v {
+				t.Errorf("dequeue expected %#x but got %#x", v, elem)
+				return
+			}
+		}
+		_, err = c.Dequeue()
+		if err != concurrent.ErrTemporaryUnavailable {
+			t.Errorf("dequeue expected ErrTemporaryUnavailable but got %v", err)
+			return
+		}
 	})
 
-	b.Run("1 consumer 4 producers", func(b *testing.B) {
-		c, p := concurrent.NewMPMCQueue[int64](defaultCapacity)
-		benchmarkMPMCQueue(b, c, p, 1, 4)
+	for _, cn := range []int{1, 4, 16} {
+		for _, pn := range []int{1, 4, 16} {
+			t.Run(fmt.Sprintf("%d consumers %d producers", cn, pn), func(t *testing.T) {
+				const n = 1 << 12
+				c, p := concurrent.NewMPMCQueueIndirectSCQ(64)
+				for i := range pn {
+					go func() {
+						for j := range n {
+							v := uintptr(math.MaxUint64) ^ uintptr(i<<32|j)
+							for p.Enqueue(v) != nil {
+								runtime.Gosched()
+							}
+						}
+					}()
+				}
+				seen := make([]atomic.Bool, pn*n)
+				consumed := atomic.Int64{}
+				wg := sync.WaitGroup{}
+				for range cn {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						for consumed.Load() < int64(pn*n) {
+							v, err := c.Dequeue()
+							if err != nil {
+								runtime.Gosched()
+								continue
+							}
+							v ^= math.MaxUint64
+							if v>>32 >= uintptr(pn) || v&math.MaxUint32 >= n || seen[(v>>32)*n+v&math.MaxUint32].Swap(true) {
+								t.Errorf("dequeue unexpected value %#x", v)
+								return
+							}
+							consumed.Add(1)
+						}
+					}()
+				}
+				wg.Wait()
+			})
+		}
+	}
+}
+
+func TestMPMCQueueWCQ(t *testing.T) {
+	t.Run("simple enqueue dequeue", func(t *testing.T) {
+		c, p := concurrent.NewMPMCQueueWCQ[int](4)
+		_, err := c.Dequeue()
+		if err != concurrent.ErrTemporaryUnavailable {
+			t.Errorf("dequeue expected ErrTemporaryUnavailable but got %v", err)
+			return
+		}
+		items := []int{100, 101, 102, 103, 104}
+		for i := range 4 {
+			err = p.Enqueue(&items[i])
+			if err != nil {
+				t.Errorf("enqueue: %v", err)
+				return
+			}
+		}
+		err = p.Enqueue(&items[4]) // full
+		if err != concurrent.ErrTemporaryUnavailable {
+			t.Errorf("enqueue expected ErrTemporaryUnavailable but got %v", err)
+			return
+		}
+		for i := range 4 {
+			elem, err := c.Dequeue()
+			if err != nil {
+				t.Errorf("dequeue: %v", err)
+				return
+			}
+			if *elem != items[i] {
+				t.Errorf("dequeue expected %v but got %v", items[i], *elem)
+				return
+			}
+		}
+		_, err = c.Dequeue()
+		if err != concurrent.ErrTemporaryUnavailable {
+			t.Errorf("dequeue expected ErrTemporaryUnavailable but got %v", err)
+			return
+		}
+	})
+
+	t.Run("invalid capacity", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("Expected panic for capacity < 2")
+			}
+		}()
+		concurrent.NewMPMCQueueWCQ[int](1)
+	})
+
+	const defaultCapacity = 1 << 8
+	for _, cn := range []int{1, 4, 16, 64} {
+		for _, pn := range []int{1, 4, 16, 64} {
+			t.Run(fmt.Sprintf("%d consumers %d producers", cn, pn), func(t *testing.T) {
+				c, p := concurrent.NewMPMCQueueWCQ[int64](defaultCapacity)
+				testMPMCQueue(t, c, p, cn, pn)
+			})
+		}
+	}
+}
+
+func TestMPMCQueueLSCQ(t *testing.T) {
+	t.Run("simple enqueue dequeue", func(t *testing.T) {
+		c, p := concurrent.NewMPMCQueueLSCQ[int](4)
+		_, err := c.Dequeue()
+		if err != concurrent.ErrTemporaryUnavailable {
+			t.Errorf("dequeue expected ErrTemporaryUnavailable but got %v", err)
+			return
+		}
+		items := make([]int, 64)
+		for i := range items {
+			items[i] = 100 + i
+			err = p.Enqueue(&items[i])
+			if err != nil {
+				t.Errorf("enqueue: %v", err)
+				return
+			}
+		}
+		for i := range items {
+			elem, err := c.Dequeue()
+			if err != nil {
+				t.Errorf("dequeue: %v", err)
+				return
+			}
+			if *elem != items[i] {
+				t.Errorf("dequeue expected %v but got %v", items[i], *elem)
+				return
+			}
+		}
+		_, err = c.Dequeue()
+		if err != concurrent.ErrTemporaryUnavailable {
+			t.Errorf("dequeue expected ErrTemporaryUnavailable but got %v", err)
+			return
+		}
+	})
+
+	t.Run("invalid capacity", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("Expected panic for capacity < 2")
+			}
+		}()
+		concurrent.NewMPMCQueueLSCQ[int](1)
+	})
+
+	const segmentCapacity = 1 << 4
+	for _, cn := range []int{1, 4, 16, 64} {
+		for _, pn := range []int{1, 4, 16, 64} {
+			t.Run(fmt.Sprintf("%d consumers %d producers", cn, pn), func(t *testing.T) {
+				c, p := concurrent.NewMPMCQueueLSCQ[int64](segmentCapacity)
+				testMPMCQueue(t, c, p, cn, pn)
+			})
+		}
+	}
+}
+
+func TestMPMCQueueNBLFQ(t *testing.T) {
+	t.Run("simple enqueue dequeue", func(t *testing.T) {
+		c, p := concurrent.NewMPMCQueueNBLFQ[int](4)
+		_, err := c.Dequeue()
+		if err != concurrent.ErrTemporaryUnavailable {
+			t.Errorf("dequeue expected ErrTemporaryUnavailable but got %v", err)
+			return
+		}
+		items := []int{100, 101, 102, 103, 104}
+		for i := range 4 {
+			err = p.Enqueue(&items[i])
+			if err != nil {
+				t.Errorf("enqueue: %v", err)
+				return
+			}
+		}
+		err = p.Enqueue(&items[4]) // full
+		if err != concurrent.ErrTemporaryUnavailable {
+			t.Errorf("enqueue expected ErrTemporaryUnavailable but got %v", err)
+			return
+		}
+		for i := range 4 {
+			elem, err := c.Dequeue()
+			if err != nil {
+				t.Errorf("dequeue: %v", err)
+				return
+			}
+			if *elem != items[i] {
+				t.Errorf("dequeue expected %v but got %v", items[i], *elem)
+				return
+			}
+		}
+		_, err = c.Dequeue()
+		if err != concurrent.ErrTemporaryUnavailable {
+			t.Errorf("dequeue expected ErrTemporaryUnavailable but got %v", err)
+			return
+		}
+	})
+
+	t.Run("invalid capacity", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("Expected panic for capacity < 2")
+			}
+		}()
+		concurrent.NewMPMCQueueNBLFQ[int](1)
+	})
+
+	const defaultCapacity = 1 << 8
+	for _, cn := range []int{1, 2, 4, 16} {
+		for _, pn := range []int{1, 2, 4, 16} {
+			t.Run(fmt.Sprintf("%d consumers %d producers", cn, pn), func(t *testing.T) {
+				c, p := concurrent.NewMPMCQueueNBLFQ[int64](defaultCapacity)
+				testMPMCQueue(t, c, p, cn, pn)
+			})
+		}
+	}
+}
+
+func TestMPMCQueueNonDistinct(t *testing.T) {
+	t.Run("simple enqueue dequeue", func(t *testing.T) {
+		c, p := concurrent.NewMPMCQueueNonDistinct[int](4)
+		items := []int{100, 101}
+		for i := range 4 {
+			err := p.Enqueue(&items[i%2])
+			if err != nil {
+				t.Errorf("enqueue: %v", err)
+				return
+			}
+		}
+		err := p.Enqueue(&items[0]) // full
+		if err != concurrent.ErrTemporaryUnavailable {
+			t.Errorf("enqueue expected ErrTemporaryUnavailable but got %v", err)
+			return
+		}
+		for i := range 4 {
+			elem, err := c.Dequeue()
+			if err != nil {
+				t.Errorf("dequeue: %v", err)
+				return
+			}
+			if elem != &items[i%2] {
+				t.Errorf("dequeue expected %v but got %v", items[i%2], *elem)
+				return
+			}
+		}
+		_, err = c.Dequeue()
+		if err != concurrent.ErrTemporaryUnavailable {
+			t.Errorf("dequeue expected ErrTemporaryUnavailable but got %v", err)
+			return
+		}
+	})
+
+	t.Run("same value concurrently", func(t *testing.T) {
+		const n, cn, pn = 1 << 10, 4, 4
+		c, p := concurrent.NewMPMCQueueNonDistinct[int](16)
+		val := 100
+		for range pn {
+			go func() {
+				for range n {
+					_ = concurrent.EnqueueWait(p, &val)
+				}
+			}()
+		}
+		wg := sync.WaitGroup{}
+		for range cn {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for range n * pn / cn {
+					elem, err := concurrent.DequeueWait(c)
+					if err != nil || elem != &val {
+						t.Errorf("dequeue expected %p but got %p, %v", &val, elem, err)
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		_, err := c.Dequeue()
+		if err != concurrent.ErrTemporaryUnavailable {
+			t.Errorf("dequeue expected ErrTemporaryUnavailable but got %v", err)
+		}
+	})
+
+	t.Run("too large capacity", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("Expected panic on invalid capacity")
+			}
+		}()
+		_, _ = concurrent.NewMPMCQueueNonDistinct[int](1<<24 + 1)
+	})
+
+	const defaultCapacity = 1 << 8
+	for _, cn := range []int{1, 4, 16, 64} {
+		for _, pn := range []int{1, 4, 16, 64} {
+			t.Run(fmt.Sprintf("%d consumers %d producers", cn, pn), func(t *testing.T) {
+				c, p := concurrent.NewMPMCQueueNonDistinct[int64](defaultCapacity)
+				testMPMCQueue(t, c, p, cn, pn)
+			})
+		}
+	}
+}
+
+func TestMPMCQueueValue(t *testing.T) {
+	type message struct {
+		id, value int64
+	}
+
+	t.Run("simple enqueue dequeue", func(t *testing.T) {
+		c, p := concurrent.NewMPMCQueueValue[message](4)
+		_, err := c.Dequeue()
+		if err != concurrent.ErrTemporaryUnavailable {
+			t.Errorf("dequeue expected ErrTemporaryUnavailable but got %v", err)
+			return
+		}
+		for i := range 4 {
+			// the same value may be queued several times
+			err = p.Enqueue(message{id: int64(i % 2), value: 100})
+			if err != nil {
+				t.Errorf("enqueue: %v", err)
+				return
+			}
+		}
+		err = p.Enqueue(message{}) // full
+		if err != concurrent.ErrTemporaryUnavailable {
+			t.Errorf("enqueue expected ErrTemporaryUnavailable but got %v", err)
+			return
+		}
+		for i := range 4 {
+			m, err := c.Dequeue()
+			if err != nil {
+				t.Errorf("dequeue: %v", err)
+				return
+			}
+			if m.id != int64(i%2) || m.value != 100 {
+				t.Errorf("dequeue expected %v but got %v", message{int64(i % 2), 100}, m)
+				return
+			}
+		}
+		_, err = c.Dequeue()
+		if err != concurrent.ErrTemporaryUnavailable {
+			t.Errorf("dequeue expected ErrTemporaryUnavailable but got %v", err)
+			return
+		}
+	})
+
+	t.Run("no allocation", func(t *testing.T) {
+		c, p := concurrent.NewMPMCQueueValue[message](4)
+		allocs := testing.AllocsPerRun(100, func() {
+			_ = p.Enqueue(message{1, 2})
+			_, _ = c.Dequeue()
+		})
+		if allocs != 0 {
+			t.Errorf("expected no allocation but got %v", allocs)
+		}
+	})
+
+	t.Run("close", func(t *testing.T) {
+		c, p := concurrent.NewMPMCQueueValue[int](4)
+		_ = p.Enqueue(1)
+		_ = p.(concurrent.Closer).Close()
+		if err := p.Enqueue(2); err != concurrent.ErrClosed {
+			t.Errorf("enqueue expected ErrClosed but got %v", err)
+			return
+		}
+		if v, err := c.Dequeue(); err != nil || v != 1 {
+			t.Errorf("dequeue expected 1 but got %v, %v", v, err)
+			return
+		}
+		if _, err := c.Dequeue(); err != concurrent.ErrClosed {
+			t.Errorf("dequeue expected ErrClosed but got %v", err)
+		}
+	})
+
+	for _, cn := range []int{1, 4, 16} {
+		for _, pn := range []int{1, 4, 16} {
+			t.Run(fmt.Sprintf("%d consumers %d producers", cn, pn), func(t *testing.T) {
+				const n = 1 << 12
+				c, p := concurrent.NewMPMCQueueValue[message](64)
+				for i := range pn {
+					go func() {
+						for j := range n {
+							for p.Enqueue(message{int64(i), int64(j)}) != nil {
+								runtime.Gosched()
+							}
+						}
+					}()
+				}
+				consumed := atomic.Int64{}
+				wg := sync.WaitGroup{}
+				for range cn {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						last := make([]int64, pn)
+						for i := range last {
+							last[i] = -1
+						}
+						for consumed.Load() < int64(pn*n) {
+							m, err := c.Dequeue()
+							if err != nil {
+								runtime.Gosched()
+								continue
+							}
+							if m.value <= last[m.id] {
+								t.Errorf("dequeue out of order %d:%d after %d", m.id, m.value, last[m.id])
+								return
+							}
+							last[m.id] = m.value
+							consumed.Add(1)
+						}
+					}()
+				}
+				wg.Wait()
+			})
+		}
+	}
+}
+
+func BenchmarkMPMCQueueRmfLF(b *testing.B) {
+	const defaultCapacity = 1 << 16
+
+	b.Run("1 consumer 1 producer", func(b *testing.B) {
+		c, p := concurrent.NewMPMCQueue[int64](defaultCapacity)
+		benchmarkMPMCQueue(b, c, p, 1, 1)
+	})
+
+	b.Run("1 consumer 4 producers", func(b *testing.B) {
+		c, p := concurrent.NewMPMCQueue[int64](defaultCapacity)
+		benchmarkMPMCQueue(b, c, p, 1, 4)
 	})
 
 	b.Run("1 consumer 16 producers", func(b *testing.B) {
@@ -474,6 +1397,139 @@ func BenchmarkMPMCQueueRmfLF(b *testing.B) {
 	})
 }
 
+func BenchmarkMPMCQueueSCQ(b *testing.B) {
+	const defaultCapacity = 1 << 16
+
+	for _, cn := range []int{1, 4, 16, 64} {
+		for _, pn := range []int{1, 4, 16, 64} {
+			b.Run(fmt.Sprintf("%d consumers %d producers", cn, pn), func(b *testing.B) {
+				c, p := concurrent.NewMPMCQueueSCQ[int64](defaultCapacity)
+				benchmarkMPMCQueue(b, c, p, cn, pn)
+			})
+		}
+	}
+}
+
+func BenchmarkSPSCQueue(b *testing.B) {
+	c, p := concurrent.NewSPSCQueue[int64](1 << 16)
+	benchmarkMPMCQueue(b, c, p, 1, 1)
+}
+
+func BenchmarkMPSCQueue(b *testing.B) {
+	const defaultCapacity = 1 << 16
+
+	for _, pn := range []int{1, 4, 16, 64} {
+		b.Run(fmt.Sprintf("1 consumer %d producers", pn), func(b *testing.B) {
+			c, p := concurrent.NewMPSCQueue[int64](defaultCapacity)
+			benchmarkMPMCQueue(b, c, p, 1, pn)
+		})
+	}
+}
+
+func BenchmarkSPMCQueue(b *testing.B) {
+	const defaultCapacity = 1 << 16
+
+	for _, cn := range []int{1, 4, 16, 64} {
+		b.Run(fmt.Sprintf("%d consumers 1 producer", cn), func(b *testing.B) {
+			c, p := concurrent.NewSPMCQueue[int64](defaultCapacity)
+			benchmarkMPMCQueue(b, c, p, cn, 1)
+		})
+	}
+}
+
+func BenchmarkMPMCQueueWCQ(b *testing.B) {
+	const defaultCapacity = 1 << 16
+
+	for _, cn := range []int{1, 4, 16, 64} {
+		for _, pn := range []int{1, 4, 16, 64} {
+			b.Run(fmt.Sprintf("%d consumers %d producers", cn, pn), func(b *testing.B) {
+				c, p := concurrent.NewMPMCQueueWCQ[int64](defaultCapacity)
+				benchmarkMPMCQueue(b, c, p, cn, pn)
+			})
+		}
+	}
+}
+
+func BenchmarkMPMCQueueLSCQ(b *testing.B) {
+	const segmentCapacity = 1 << 10
+
+	for _, cn := range []int{1, 4, 16, 64} {
+		for _, pn := range []int{1, 4, 16, 64} {
+			b.Run(fmt.Sprintf("%d consumers %d producers", cn, pn), func(b *testing.B) {
+				c, p := concurrent.NewMPMCQueueLSCQ[int64](segmentCapacity)
+				benchmarkMPMCQueue(b, c, p, cn, pn)
+			})
+		}
+	}
+}
+
+func BenchmarkMPMCQueueBatch(b *testing.B) {
+	const batch = 16
+	items := make([]int64, batch)
+	elems := make([]*int64, batch)
+	for i := range elems {
+		elems[i] = &items[i]
+	}
+
+	b.Run("single", func(b *testing.B) {
+		c, p := concurrent.NewMPMCQueue[int64](1 << 10)
+		for i := 0; i < b.N; i++ {
+			for _, e := range elems {
+				_ = p.Enqueue(e)
+			}
+			for range elems {
+				_, _ = c.Dequeue()
+			}
+		}
+	})
+	b.Run("batch", func(b *testing.B) {
+		c, p := concurrent.NewMPMCQueue[int64](1 << 10)
+		pb, cb := p.(concurrent.ProducerBatch[int64]), c.(concurrent.ConsumerBatch[int64])
+		out := make([]*int64, batch)
+		for i := 0; i < b.N; i++ {
+			_, _ = pb.EnqueueBatch(elems)
+			_, _ = cb.DequeueBatch(out)
+		}
+	})
+}
+
+func BenchmarkMPMCQueueValue(b *testing.B) {
+	b.Run("pointer", func(b *testing.B) {
+		c, p := concurrent.NewMPMCQueue[int64](1 << 10)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			v := int64(i)
+			_ = p.Enqueue(&v)
+			_, _ = c.Dequeue()
+		}
+	})
+	b.Run("value", func(b *testing.B) {
+		c, p := concurrent.NewMPMCQueueValue[int64](1 << 10)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = p.Enqueue(int64(i))
+			_, _ = c.Dequeue()
+		}
+	})
+}
+
+func BenchmarkMPMCQueueLowContention(b *testing.B) {
+	const defaultCapacity = 1 << 16
+
+	for _, cn := range []int{1, 2} {
+		for _, pn := range []int{1, 2} {
+			b.Run(fmt.Sprintf("rmfLF %d consumers %d producers", cn, pn), func(b *testing.B) {
+				c, p := concurrent.NewMPMCQueue[int64](defaultCapacity)
+				benchmarkMPMCQueue(b, c, p, cn, pn)
+			})
+			b.Run(fmt.Sprintf("NBLFQ %d consumers %d producers", cn, pn), func(b *testing.B) {
+				c, p := concurrent.NewMPMCQueueNBLFQ[int64](defaultCapacity)
+				benchmarkMPMCQueue(b, c, p, cn, pn)
+			})
+		}
+	}
+}
+
 func TestEnqueueDequeueWait(t *testing.T) {
 	c, p := concurrent.NewMPMCQueue[int](2)
 	e1, e2, e3 := 1, 2, 3
@@ -521,7 +1577,558 @@ func TestEnqueueDequeueWait(t *testing.T) {
 	}
 }
 
+func TestEnqueueDequeueWaitContext(t *testing.T) {
+	t.Run("canceled", func(t *testing.T) {
+		c, p := concurrent.NewMPMCQueue[int](2)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := concurrent.DequeueWaitContext(ctx, c)
+		if err != context.Canceled {
+			t.Errorf("dequeue wait expected context.Canceled but got %v", err)
+			return
+		}
+		items := []int{100, 101, 102}
+		for i := range 2 {
+			err = concurrent.EnqueueWaitContext(ctx, p, &items[i])
+			if err != nil {
+				t.Errorf("enqueue wait: %v", err)
+				return
+			}
+		}
+		err = concurrent.EnqueueWaitContext(ctx, p, &items[2])
+		if err != context.Canceled {
+			t.Errorf("enqueue wait expected context.Canceled but got %v", err)
+			return
+		}
+		elem, err := concurrent.DequeueWaitContext(ctx, c)
+		if err != nil {
+			t.Errorf("dequeue wait: %v", err)
+			return
+		}
+		if *elem != items[0] {
+			t.Errorf("dequeue wait expected %v but got %v", items[0], *elem)
+			return
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		c, p := concurrent.NewMPMCQueue[int](2)
+		_, err := concurrent.DequeueWaitTimeout(c, time.Millisecond)
+		if err != context.DeadlineExceeded {
+			t.Errorf("dequeue wait expected context.DeadlineExceeded but got %v", err)
+			return
+		}
+		items := []int{100, 101, 102}
+		for i := range 2 {
+			err = concurrent.EnqueueWaitTimeout(p, &items[i], time.Millisecond)
+			if err != nil {
+				t.Errorf("enqueue wait: %v", err)
+				return
+			}
+		}
+		err = concurrent.EnqueueWaitTimeout(p, &items[2], time.Millisecond)
+		if err != context.DeadlineExceeded {
+			t.Errorf("enqueue wait expected context.DeadlineExceeded but got %v", err)
+			return
+		}
+	})
+
+	t.Run("wake up before deadline", func(t *testing.T) {
+		c, p := concurrent.NewMPMCQueue[int](2)
+		item := 100
+		go func() {
+			time.Sleep(time.Millisecond)
+			_ = p.Enqueue(&item)
+		}()
+		elem, err := concurrent.DequeueWaitTimeout(c, time.Minute)
+		if err != nil {
+			t.Errorf("dequeue wait: %v", err)
+			return
+		}
+		if *elem != item {
+			t.Errorf("dequeue wait expected %v but got %v", item, *elem)
+			return
+		}
+	})
+
+	t.Run("closed", func(t *testing.T) {
+		c, p := concurrent.NewMPMCQueue[int](2)
+		_ = p.(concurrent.Closer).Close()
+		_, err := concurrent.DequeueWaitContext(context.Background(), c)
+		if err != concurrent.ErrClosed {
+			t.Errorf("dequeue wait expected ErrClosed but got %v", err)
+			return
+		}
+	})
+}
+
+func TestEnqueueDequeueWaitParking(t *testing.T) {
+	t.Run("wake up consumers", func(t *testing.T) {
+		const cn = 8
+		c, p := concurrent.NewMPMCQueue[int](16)
+		items := make([]int, cn)
+		wg := sync.WaitGroup{}
+		for range cn {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := concurrent.DequeueWait(c)
+				if err != nil {
+					t.Errorf("dequeue wait: %v", err)
+				}
+			}()
+		}
+		time.Sleep(time.Millisecond)
+		for i := range items {
+			err := p.Enqueue(&items[i])
+			if err != nil {
+				t.Errorf("enqueue: %v", err)
+				return
+			}
+		}
+		wg.Wait()
+	})
+
+	t.Run("wake up producers", func(t *testing.T) {
+		const pn = 8
+		c, p := concurrent.NewSPMCQueue[int](2)
+		items := make([]int, 2+pn)
+		for i := range 2 {
+			_ = p.Enqueue(&items[i])
+		}
+		wg := sync.WaitGroup{}
+		mu := sync.Mutex{} // SPMCQueue allows a single producer at a time
+		for i := range pn {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				mu.Lock()
+				defer mu.Unlock()
+				err := concurrent.EnqueueWait(p, &items[2+i])
+				if err != nil {
+					t.Errorf("enqueue wait: %v", err)
+				}
+			}()
+		}
+		for range 2 + pn {
+			_, err := concurrent.DequeueWait(c)
+			if err != nil {
+				t.Errorf("dequeue wait: %v", err)
+				return
+			}
+		}
+		wg.Wait()
+	})
+
+	t.Run("wake up on close", func(t *testing.T) {
+		c, p := concurrent.NewMPSCQueue[int](2)
+		done := make(chan error)
+		go func() {
+			_, err := concurrent.DequeueWait(c)
+			done <- err
+		}()
+		time.Sleep(time.Millisecond)
+		_ = p.(concurrent.Closer).Close()
+		err := <-done
+		if err != concurrent.ErrClosed {
+			t.Errorf("dequeue wait expected ErrClosed but got %v", err)
+		}
+	})
+
+	t.Run("cancel parked", func(t *testing.T) {
+		c, p := concurrent.NewSPSCQueue[int](2)
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() {
+			_, err := concurrent.DequeueWaitContext(ctx, c)
+			done <- err
+		}()
+		time.Sleep(time.Millisecond)
+		cancel()
+		err := <-done
+		if err != context.Canceled {
+			t.Errorf("dequeue wait expected context.Canceled but got %v", err)
+			return
+		}
+		item := 100
+		_ = p.Enqueue(&item)
+		elem, err := concurrent.DequeueWait(c)
+		if err != nil || *elem != item {
+			t.Errorf("dequeue wait expected %v but got %v, %v", item, elem, err)
+		}
+	})
+}
+
+func TestQueueKeepAlive(t *testing.T) {
+	type queue = func(capacity int) (concurrent.Consumer[[16]int64], concurrent.Producer[[16]int64])
+	withOptions := func(opts ...func(*concurrent.QueueOptions)) queue {
+		return func(capacity int) (concurrent.Consumer[[16]int64], concurrent.Producer[[16]int64]) {
+			return concurrent.NewQueue[[16]int64](capacity, opts...)
+		}
+	}
+	queues := []struct {
+		name string
+		new  queue
+	}{
+		{"default", withOptions()},
+		{"single producer single consumer", withOptions(concurrent.WithSingleProducer(), concurrent.WithSingleConsumer())},
+		{"single consumer", withOptions(concurrent.WithSingleConsumer())},
+		{"single producer", withOptions(concurrent.WithSingleProducer())},
+		{"wait-free", withOptions(concurrent.WithWaitFree())},
+		{"low contention", withOptions(concurrent.WithLowContention())},
+		{"non-distinct values", withOptions(concurrent.WithDistinctValues(false))},
+		{"SCQ", concurrent.NewMPMCQueueSCQ[[16]int64]},
+		{"LSCQ", concurrent.NewMPMCQueueLSCQ[[16]int64]},
+	}
+	for _, q := range queues {
+		t.Run(q.name, func(t *testing.T) {
+			testQueueKeepAlive(t, q.new)
+		})
+	}
+}
+
+func TestQueueSizer(t *testing.T) {
+	queues := []struct {
+		name string
+		new  func(capacity int) (concurrent.Consumer[int], concurrent.Producer[int])
+	}{
+		{"SPSC", concurrent.NewSPSCQueue[int]},
+		{"MPSC", concurrent.NewMPSCQueue[int]},
+		{"SPMC", concurrent.NewSPMCQueue[int]},
+		{"MPMC", concurrent.NewMPMCQueue[int]},
+		{"SCQ", concurrent.NewMPMCQueueSCQ[int]},
+		{"WCQ", concurrent.NewMPMCQueueWCQ[int]},
+		{"NBLFQ", concurrent.NewMPMCQueueNBLFQ[int]},
+		{"NonDistinct", concurrent.NewMPMCQueueNonDistinct[int]},
+	}
+	for _, q := range queues {
+		t.Run(q.name, func(t *testing.T) {
+			c, p := q.new(3)
+			s := p.(concurrent.Sizer)
+			if s.Cap() != 4 {
+				t.Errorf("cap expected 4 but got %v", s.Cap())
+				return
+			}
+			if s.Len() != 0 || !s.IsEmpty() || s.IsFull() {
+				t.Errorf("expected empty but got len %v", s.Len())
+				return
+			}
+			items := make([]int, 4)
+			for i := range items {
+				_ = p.Enqueue(&items[i])
+				if s.Len() != i+1 {
+					t.Errorf("len expected %v but got %v", i+1, s.Len())
+					return
+				}
+			}
+			if !s.IsFull() || s.IsEmpty() {
+				t.Errorf("expected full but got len %v", s.Len())
+				return
+			}
+			_, _ = c.Dequeue()
+			if s.Len() != 3 || s.IsFull() {
+				t.Errorf("len expected 3 but got %v", s.Len())
+				return
+			}
+		})
+	}
+
+	t.Run("LSCQ", func(t *testing.T) {
+		_, p := concurrent.NewMPMCQueueLSCQ[int](4)
+		s := p.(concurrent.Sizer)
+		if s.Cap() != math.MaxInt || !s.IsEmpty() {
+			t.Errorf("expected empty unbounded queue but got cap %v len %v", s.Cap(), s.Len())
+			return
+		}
+		items := make([]int, 10)
+		for i := range items {
+			_ = p.Enqueue(&items[i])
+		}
+		if s.Len() != len(items) || s.IsFull() {
+			t.Errorf("len expected %v but got %v", len(items), s.Len())
+			return
+		}
+	})
+
+	t.Run("Indirect", func(t *testing.T) {
+		_, p := concurrent.NewMPMCQueueIndirect(3)
+		s := p.(concurrent.Sizer)
+		_ = p.Enqueue(42)
+		if s.Cap() != 4 || s.Len() != 1 {
+			t.Errorf("expected cap 4 len 1 but got cap %v len %v", s.Cap(), s.Len())
+		}
+	})
+}
+
+func TestQueueClose(t *testing.T) {
+	queues := []struct {
+		name   string
+		new    func(capacity int) (concurrent.Consumer[int], concurrent.Producer[int])
+		cn, pn int
+	}{
+		{"SPSC", concurrent.NewSPSCQueue[int], 1, 1},
+		{"MPSC", concurrent.NewMPSCQueue[int], 1, 4},
+		{"SPMC", concurrent.NewSPMCQueue[int], 4, 1},
+		{"MPMC", concurrent.NewMPMCQueue[int], 4, 4},
+		{"SCQ", concurrent.NewMPMCQueueSCQ[int], 4, 4},
+		{"WCQ", concurrent.NewMPMCQueueWCQ[int], 4, 4},
+		{"LSCQ", concurrent.NewMPMCQueueLSCQ[int], 4, 4},
+		{"NBLFQ", concurrent.NewMPMCQueueNBLFQ[int], 4, 4},
+		{"NonDistinct", concurrent.NewMPMCQueueNonDistinct[int], 4, 4},
+		{"Value", newValueQueue[int], 4, 4},
+	}
+	for _, q := range queues {
+		t.Run(q.name, func(t *testing.T) {
+			c, p := q.new(4)
+			items := []int{100, 101, 102}
+			for i := range 2 {
+				err := p.Enqueue(&items[i])
+				if err != nil {
+					t.Errorf("enqueue: %v", err)
+					return
+				}
+			}
+			err := p.(concurrent.Closer).Close()
+			if err != nil {
+				t.Errorf("close: %v", err)
+				return
+			}
+			err = p.Enqueue(&items[2])
+			if err != concurrent.ErrClosed {
+				t.Errorf("enqueue expected ErrClosed but got %v", err)
+				return
+			}
+			err = concurrent.EnqueueWait(p, &items[2])
+			if err != concurrent.ErrClosed {
+				t.Errorf("enqueue wait expected ErrClosed but got %v", err)
+				return
+			}
+			for i := range 2 {
+				elem, err := c.Dequeue()
+				if err != nil {
+					t.Errorf("dequeue: %v", err)
+					return
+				}
+				if *elem != items[i] {
+					t.Errorf("dequeue expected %v but got %v", items[i], *elem)
+					return
+				}
+			}
+			_, err = c.Dequeue()
+			if err != concurrent.ErrClosed {
+				t.Errorf("dequeue expected ErrClosed but got %v", err)
+				return
+			}
+			_, err = concurrent.DequeueWait(c)
+			if err != concurrent.ErrClosed {
+				t.Errorf("dequeue wait expected ErrClosed but got %v", err)
+				return
+			}
+			err = c.(concurrent.Closer).Close()
+			if err != concurrent.ErrClosed {
+				t.Errorf("close again expected ErrClosed but got %v", err)
+				return
+			}
+		})
+	}
+
+	for _, q := range queues {
+		t.Run(q.name+" close races with enqueue", func(t *testing.T) {
+			for range 1 << 11 {
+				if !testQueueCloseRace(t, q.new, q.cn, q.pn) {
+					return
+				}
+			}
+		})
+	}
+
+	t.Run("Indirect", func(t *testing.T) {
+		c, p := concurrent.NewMPMCQueueIndirect(4)
+		err := p.Enqueue(42)
+		if err != nil {
+			t.Errorf("enqueue: %v", err)
+			return
+		}
+		_ = p.(concurrent.Closer).Close()
+		err = p.Enqueue(43)
+		if err != concurrent.ErrClosed {
+			t.Errorf("enqueue expected ErrClosed but got %v", err)
+			return
+		}
+		elem, err := c.Dequeue()
+		if err != nil || elem != 42 {
+			t.Errorf("dequeue expected 42 but got %v, %v", elem, err)
+			return
+		}
+		_, err = c.Dequeue()
+		if err != concurrent.ErrClosed {
+			t.Errorf("dequeue expected ErrClosed but got %v", err)
+			return
+		}
+	})
+
+	t.Run("drain on close", func(t *testing.T) {
+		const cn, pn, n = 4, 4, 1 << 12
+		c, p := concurrent.NewMPMCQueue[int](64)
+		items := make([]int, pn*n)
+		consumed := atomic.Int64{}
+		wg := sync.WaitGroup{}
+		for range cn {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					_, err := concurrent.DequeueWait(c)
+					if err == concurrent.ErrClosed {
+						return
+					}
+					if err != nil {
+						t.Errorf("dequeue wait: %v", err)
+						return
+					}
+					consumed.Add(1)
+				}
+			}()
+		}
+		pwg := sync.WaitGroup{}
+		for i := range pn {
+			pwg.Add(1)
+			go func() {
+				defer pwg.Done()
+				for j := range n {
+					err := concurrent.EnqueueWait(p, &items[i*n+j])
+					if err != nil {
+						t.Errorf("enqueue wait: %v", err)
+						return
+					}
+				}
+			}()
+		}
+		pwg.Wait()
+		_ = p.(concurrent.Closer).Close()
+		wg.Wait()
+		if consumed.Load() != pn*n {
+			t.Errorf("consumed expected %v but got %v", pn*n, consumed.Load())
+		}
+	})
+}
+
 // Test utilities for MPMC queues (interface-based, reusable)
+// testQueueCloseRace closes the queue while producers are enqueuing
+// and checks that the consumers get every accepted item before ErrClosed
+func testQueueCloseRace(t *testing.T, newQueue func(capacity int) (concurrent.Consumer[int], concurrent.Producer[int]), cn, pn int) bool {
+	c, p := newQueue(4)
+	accepted, consumed := atomic.Int64{}, atomic.Int64{}
+	wg := sync.WaitGroup{}
+	for range pn {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				err := p.Enqueue(new(int))
+				if err == concurrent.ErrClosed {
+					return
+				}
+				if err == nil {
+					accepted.Add(1)
+				}
+				runtime.Gosched()
+			}
+		}()
+	}
+	for range cn {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				_, err := concurrent.DequeueWait(c)
+				if err == concurrent.ErrClosed {
+					return
+				}
+				if err != nil {
+					t.Errorf("dequeue wait: %v", err)
+					return
+				}
+				consumed.Add(1)
+			}
+		}()
+	}
+	for accepted.Load() < 8 {
+		runtime.Gosched()
+	}
+	_ = p.(concurrent.Closer).Close()
+	wg.Wait()
+	if consumed.Load() != accepted.Load() {
+		t.Errorf("consumed expected %v but got %v", accepted.Load(), consumed.Load())
+		return false
+	}
+
+	return true
+}
+
+// valueQueue adapts a queue which stores the items by value
+// to the Consumer and Producer interfaces, the embedded queue
+// lets EnqueueWait and DequeueWait park on it
+type valueQueue[T any] struct {
+	*concurrent.MPMCQueueValue[T]
+}
+
+func newValueQueue[T any](capacity int) (concurrent.Consumer[T], concurrent.Producer[T]) {
+	_, p := concurrent.NewMPMCQueueValue[T](capacity)
+	q := valueQueue[T]{p.(*concurrent.MPMCQueueValue[T])}
+
+	return q, q
+}
+
+func (q valueQueue[T]) Enqueue(elem *T) error {
+	return q.MPMCQueueValue.Enqueue(*elem)
+}
+
+func (q valueQueue[T]) Dequeue() (elem *T, err error) {
+	v, err := q.MPMCQueueValue.Dequeue()
+	if err != nil {
+		return nil, err
+	}
+
+	return &v, nil
+}
+
+// testQueueKeepAlive checks that queued items stay reachable
+// after the producer drops its only reference
+func testQueueKeepAlive(t *testing.T, newQueue func(capacity int) (concurrent.Consumer[[16]int64], concurrent.Producer[[16]int64])) {
+	const n = 64
+	c, p := newQueue(n)
+	freed := atomic.Int64{}
+	for i := range n {
+		elem := &[16]int64{int64(i)}
+		runtime.SetFinalizer(elem, func(*[16]int64) { freed.Add(1) })
+		err := p.Enqueue(elem)
+		if err != nil {
+			t.Errorf("enqueue: %v", err)
+			return
+		}
+	}
+	for range 3 {
+		runtime.GC()
+		_ = make([][16]int64, 1<<10)
+	}
+	if freed.Load() != 0 {
+		t.Errorf("%d queued items freed by GC", freed.Load())
+		return
+	}
+	for i := range n {
+		elem, err := c.Dequeue()
+		if err != nil {
+			t.Errorf("dequeue: %v", err)
+			return
+		}
+		if elem[0] != int64(i) {
+			t.Errorf("dequeue expected %v but got %v", i, elem[0])
+			return
+		}
+	}
+}
+
 func testMPMCQueue(t *testing.T, c concurrent.Consumer[int64], p concurrent.Producer[int64], cn, pn int) {
 	n := 1 << 12
 	for i := 0; i < pn; i++ {
@@ -0,0 +1,155 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package concurrent
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/sys/cpu"
+)
+
+// HazardDomain represents a domain of hazard pointers protecting nodes of type T.
+// A goroutine publishes the nodes it is about to access in the slots of its
+// HazardRecord, retired nodes are reclaimed once no slot holds them.
+// Unlike EpochDomain, a stalled goroutine only delays the nodes it protects
+type HazardDomain[T any] struct {
+	records   atomic.Pointer[HazardRecord[T]]
+	pool      sync.Pool
+	slots     int
+	threshold int
+	mu        sync.Mutex
+	orphans   []hazardRetired[T]
+}
+
+// NewHazardDomain creates a new hazard pointer domain where every record has
+// the given number of slots, a record scans the domain whenever it holds
+// threshold retired nodes
+func NewHazardDomain[T any](slots, threshold int) *HazardDomain[T] {
+	if slots < 1 {
+		panic("bad slots")
+	}
+	if threshold < 1 {
+		panic("bad threshold")
+	}
+
+	return &HazardDomain[T]{slots: slots, threshold: threshold}
+}
+
+// Acquire returns an idle record of the domain, the record must be released
+func (d *HazardDomain[T]) Acquire() *HazardRecord[T] {
+	if r, ok := d.pool.Get().(*HazardRecord[T]); ok && r.inUse.CompareAndSwap(false, true) {
+		return r
+	}
+	for r := d.records.Load(); r != nil; r = r.next {
+		if !r.inUse.Load() && r.inUse.CompareAndSwap(false, true) {
+			return r
+		}
+	}
+	r := &HazardRecord[T]{slots: make([]atomic.Pointer[T], d.slots), d: d}
+	r.inUse.Store(true)
+	for {
+		r.next = d.records.Load()
+		if d.records.CompareAndSwap(r.next, r) {
+			return r
+		}
+	}
+}
+
+// HazardRecord represents the hazard pointers of a goroutine.
+// Each record must only be used by one goroutine at a time
+type HazardRecord[T any] struct {
+	_       cpu.CacheLinePad
+	slots   []atomic.Pointer[T]
+	inUse   atomic.Bool
+	_       cpu.CacheLinePad
+	next    *HazardRecord[T]
+	d       *HazardDomain[T]
+	retired []hazardRetired[T]
+}
+
+type hazardRetired[T any] struct {
+	p       *T
+	reclaim func(*T)
+}
+
+// Protect loads the node from src and publishes it in slot i,
+// the returned node will not be reclaimed until slot i is cleared or reused
+func (r *HazardRecord[T]) Protect(i int, src *atomic.Pointer[T]) *T {
+	p := src.Load()
+	for {
+		r.slots[i].Store(p)
+		q := src.Load()
+		if q == p {
+			return p
+		}
+		p = q
+	}
+}
+
+// Clear clears slot i
+func (r *HazardRecord[T]) Clear(i int) {
+	r.slots[i].Store(nil)
+}
+
+// Retire defers reclaim(p) until no slot of the domain holds p.
+// p must have been unlinked so that no goroutine can protect it anew
+func (r *HazardRecord[T]) Retire(p *T, reclaim func(*T)) {
+	r.retired = append(r.retired, hazardRetired[T]{p: p, reclaim: reclaim})
+	if len(r.retired) >= r.d.threshold {
+		r.Scan()
+	}
+}
+
+// Scan reclaims the nodes retired by r and by released records
+// which no slot of the domain holds
+func (r *HazardRecord[T]) Scan() {
+	d := r.d
+	d.mu.Lock()
+	r.retired = append(r.retired, d.orphans...)
+	clear(d.orphans)
+	d.orphans = d.orphans[:0]
+	d.mu.Unlock()
+
+	hazards := make(map[*T]struct{})
+	for rec := d.records.Load(); rec != nil; rec = rec.next {
+		for i := range rec.slots {
+			if p := rec.slots[i].Load(); p != nil {
+				hazards[p] = struct{}{}
+			}
+		}
+	}
+	retired := r.retired[:0]
+	var ready []hazardRetired[T]
+	for _, h := range r.retired {
+		if _, ok := hazards[h.p]; ok {
+			retired = append(retired, h)
+		} else {
+			ready = append(ready, h)
+		}
+	}
+	clear(r.retired[len(retired):])
+	r.retired = retired
+	for _, h := range ready {
+		h.reclaim(h.p)
+	}
+}
+
+// Release clears all slots and returns r to the domain,
+// nodes retired by r are scanned later by other records
+func (r *HazardRecord[T]) Release() {
+	for i := range r.slots {
+		r.slots[i].Store(nil)
+	}
+	if len(r.retired) > 0 {
+		r.d.mu.Lock()
+		r.d.orphans = append(r.d.orphans, r.retired...)
+		r.d.mu.Unlock()
+		clear(r.retired)
+		r.retired = r.retired[:0]
+	}
+	r.inUse.Store(false)
+	r.d.pool.Put(r)
+}
@@ -0,0 +1,203 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package concurrent_test
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"code.hybscloud.com/concurrent"
+)
+
+func TestHazardDomain(t *testing.T) {
+	t.Run("stalled reader", func(t *testing.T) {
+		d := concurrent.NewHazardDomain[reclaimNode](1, 4)
+		src := atomic.Pointer[reclaimNode]{}
+		protected := &reclaimNode{}
+		src.Store(protected)
+		stalled := d.Acquire()
+		if stalled.Protect(0, &src) != protected {
+			t.Error("protect expected the node stored in src")
+			return
+		}
+		src.Store(nil)
+
+		reclaimed := map[*reclaimNode]bool{}
+		r := d.Acquire()
+		nodes := []*reclaimNode{protected, {}, {}, {}, {}, {}, {}, {}}
+		for _, n := range nodes {
+			r.Retire(n, func(n *reclaimNode) { reclaimed[n] = true })
+		}
+		r.Scan()
+		if reclaimed[protected] || len(reclaimed) != len(nodes)-1 {
+			t.Errorf("scan expected to reclaim %v nodes except the protected one but got %v", len(nodes)-1, len(reclaimed))
+			return
+		}
+		stalled.Clear(0)
+		r.Scan()
+		if !reclaimed[protected] {
+			t.Error("scan expected to reclaim the node after clear")
+		}
+		r.Release()
+		stalled.Release()
+	})
+
+	t.Run("orphans", func(t *testing.T) {
+		d := concurrent.NewHazardDomain[reclaimNode](1, 64)
+		r := d.Acquire()
+		reclaimed := atomic.Bool{}
+		r.Retire(&reclaimNode{}, func(*reclaimNode) { reclaimed.Store(true) })
+		r.Release()
+		r = d.Acquire()
+		r.Scan()
+		if !reclaimed.Load() {
+			t.Error("scan expected to reclaim nodes retired by released records")
+		}
+		r.Release()
+		expectPanic(t, "bad slots", func() { concurrent.NewHazardDomain[reclaimNode](0, 1) })
+		expectPanic(t, "bad threshold", func() { concurrent.NewHazardDomain[reclaimNode](1, 0) })
+	})
+
+	for _, n := range []int{2, 4, 16} {
+		t.Run(fmt.Sprintf("stack with %d goroutines", n), func(t *testing.T) {
+			testReclaimStructure(t, n, newHazardStack())
+		})
+		t.Run(fmt.Sprintf("queue with %d goroutines", n), func(t *testing.T) {
+			testReclaimStructure(t, n, newHazardQueue())
+		})
+	}
+}
+
+// hazardDomain gives every goroutine its own hazard record
+type hazardDomain struct {
+	d *concurrent.HazardDomain[reclaimNode]
+}
+
+func newHazardDomain() hazardDomain {
+	return hazardDomain{d: concurrent.NewHazardDomain[reclaimNode](2, 16)}
+}
+
+func (d hazardDomain) acquire() *concurrent.HazardRecord[reclaimNode] {
+	return d.d.Acquire()
+}
+
+func (d hazardDomain) release(r *concurrent.HazardRecord[reclaimNode]) {
+	r.Release()
+}
+
+// Treiber stack protected by hazard pointers
+type hazardStack struct {
+	reclaimFreeList
+	hazardDomain
+	head atomic.Pointer[reclaimNode]
+}
+
+func newHazardStack() *hazardStack {
+	return &hazardStack{reclaimFreeList: newReclaimFreeList(), hazardDomain: newHazardDomain()}
+}
+
+func (s *hazardStack) push(r *concurrent.HazardRecord[reclaimNode], v int64) {
+	n := s.node(v)
+	for {
+		h := s.head.Load()
+		n.next.Store(h)
+		if s.head.CompareAndSwap(h, n) {
+			return
+		}
+	}
+}
+
+func (s *hazardStack) pop(t *testing.T, r *concurrent.HazardRecord[reclaimNode]) (int64, bool) {
+	defer r.Clear(0)
+	for {
+		h := r.Protect(0, &s.head)
+		if h == nil {
+			return 0, false
+		}
+		next := h.next.Load()
+		expectLive(t, h)
+		if s.head.CompareAndSwap(h, next) {
+			v := h.value.Load()
+			r.Retire(h, s.reclaim)
+			return v, true
+		}
+	}
+}
+
+// Michael-Scott queue protected by hazard pointers
+type hazardQueue struct {
+	reclaimFreeList
+	hazardDomain
+	head, tail atomic.Pointer[reclaimNode]
+}
+
+func newHazardQueue() *hazardQueue {
+	q := &hazardQueue{reclaimFreeList: newReclaimFreeList(), hazardDomain: newHazardDomain()}
+	dummy := q.node(0)
+	q.head.Store(dummy)
+	q.tail.Store(dummy)
+
+	return q
+}
+
+func (q *hazardQueue) push(r *concurrent.HazardRecord[reclaimNode], v int64) {
+	defer r.Clear(0)
+	n := q.node(v)
+	for {
+		t := r.Protect(0, &q.tail)
+		next := t.next.Load()
+		if t != q.tail.Load() {
+			continue
+		}
+		if next != nil {
+			q.tail.CompareAndSwap(t, next)
+			continue
+		}
+		if t.next.CompareAndSwap(nil, n) {
+			q.tail.CompareAndSwap(t, n)
+			return
+		}
+	}
+}
+
+func (q *hazardQueue) pop(t *testing.T, r *concurrent.HazardRecord[reclaimNode]) (int64, bool) {
+	defer r.Clear(0)
+	defer r.Clear(1)
+	for {
+		h := r.Protect(0, &q.head)
+		next := r.Protect(1, &h.next)
+		if h != q.head.Load() {
+			continue
+		}
+		if next == nil {
+			return 0, false
+		}
+		if h == q.tail.Load() {
+			q.tail.CompareAndSwap(h, next)
+			continue
+		}
+		v := next.value.Load()
+		expectLive(t, h, next)
+		if q.head.CompareAndSwap(h, next) {
+			r.Retire(h, q.reclaim)
+			return v, true
+		}
+	}
+}
+
+func BenchmarkHazardDomain(b *testing.B) {
+	d := concurrent.NewHazardDomain[reclaimNode](1, 64)
+	src := atomic.Pointer[reclaimNode]{}
+	src.Store(&reclaimNode{})
+	b.RunParallel(func(pb *testing.PB) {
+		r := d.Acquire()
+		defer r.Release()
+		for pb.Next() {
+			r.Protect(0, &src)
+			r.Clear(0)
+		}
+	})
+}
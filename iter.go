@@ -0,0 +1,51 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package concurrent
+
+import (
+	"context"
+	"iter"
+)
+
+// Drain returns an iterator which dequeues items from c
+// until the queue is empty or closed, it never blocks
+func Drain[T any](c Consumer[T]) iter.Seq[*T] {
+	return func(yield func(*T) bool) {
+		for {
+			elem, err := c.Dequeue()
+			if err != nil || !yield(elem) {
+				return
+			}
+		}
+	}
+}
+
+// All returns an iterator which dequeues items from c and waits for more
+// until the queue is closed and drained or ctx is done
+func All[T any](ctx context.Context, c Consumer[T]) iter.Seq[*T] {
+	return func(yield func(*T) bool) {
+		for {
+			elem, err := DequeueWaitContext(ctx, c)
+			if err != nil || !yield(elem) {
+				return
+			}
+		}
+	}
+}
+
+// EnqueueAll enqueues the items of seq in order and waits while the queue is full.
+// It returns the number of enqueued items and ErrClosed if the queue
+// has been closed before seq is exhausted
+func EnqueueAll[T any](p Producer[T], seq iter.Seq[*T]) (n int, err error) {
+	for elem := range seq {
+		err = EnqueueWait(p, elem)
+		if err != nil {
+			return
+		}
+		n++
+	}
+
+	return
+}
@@ -0,0 +1,100 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package concurrent_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"code.hybscloud.com/concurrent"
+)
+
+func TestDrain(t *testing.T) {
+	c, p := concurrent.NewMPMCQueue[int](8)
+	items := []int{100, 101, 102}
+	for i := range items {
+		_ = p.Enqueue(&items[i])
+	}
+	got := []int{}
+	for elem := range concurrent.Drain(c) {
+		got = append(got, *elem)
+	}
+	if !slices.Equal(got, items) {
+		t.Errorf("drain expected %v but got %v", items, got)
+		return
+	}
+
+	// stop early and keep the rest queued
+	for i := range items {
+		_ = p.Enqueue(&items[i])
+	}
+	for range concurrent.Drain(c) {
+		break
+	}
+	s := p.(concurrent.Sizer)
+	if s.Len() != 2 {
+		t.Errorf("len expected 2 but got %v", s.Len())
+	}
+}
+
+func TestAll(t *testing.T) {
+	t.Run("until closed", func(t *testing.T) {
+		c, p := concurrent.NewMPMCQueue[int](4)
+		items := make([]int, 64)
+		elems := make([]*int, len(items))
+		for i := range items {
+			items[i] = i
+			elems[i] = &items[i]
+		}
+		go func() {
+			n, err := concurrent.EnqueueAll(p, slices.Values(elems))
+			if n != len(items) || err != nil {
+				t.Errorf("enqueue all expected %v, <nil> but got %v, %v", len(items), n, err)
+			}
+			_ = p.(concurrent.Closer).Close()
+		}()
+		got := []int{}
+		for elem := range concurrent.All(context.Background(), c) {
+			got = append(got, *elem)
+		}
+		if !slices.Equal(got, items) {
+			t.Errorf("all expected %v but got %v", items, got)
+		}
+	})
+
+	t.Run("until canceled", func(t *testing.T) {
+		c, _ := concurrent.NewMPMCQueue[int](4)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		defer cancel()
+		for range concurrent.All(ctx, c) {
+			t.Error("all expected no item")
+		}
+	})
+}
+
+func TestEnqueueAll(t *testing.T) {
+	c, p := concurrent.NewMPMCQueue[int](4)
+	items := []int{100, 101, 102}
+	n, err := concurrent.EnqueueAll(p, slices.Values([]*int{&items[0], &items[1], &items[2]}))
+	if n != 3 || err != nil {
+		t.Errorf("enqueue all expected 3, <nil> but got %v, %v", n, err)
+		return
+	}
+	_ = p.(concurrent.Closer).Close()
+	n, err = concurrent.EnqueueAll(p, slices.Values([]*int{&items[0]}))
+	if n != 0 || err != concurrent.ErrClosed {
+		t.Errorf("enqueue all expected 0, ErrClosed but got %v, %v", n, err)
+		return
+	}
+	got := []int{}
+	for elem := range concurrent.Drain(c) {
+		got = append(got, *elem)
+	}
+	if !slices.Equal(got, items) {
+		t.Errorf("drain expected %v but got %v", items, got)
+	}
+}
@@ -0,0 +1,87 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package concurrent
+
+import (
+	"sync/atomic"
+
+	"golang.org/x/sys/cpu"
+)
+
+// linked list of sCQ rings
+//
+// Every ring keeps its elements in a slot array indexed by two sCQ rings.
+// Enqueuers finalize the tail ring once it is full and link a new ring
+// which already holds their element. Dequeuers move to the next ring
+// when the head ring is drained, the drained ring is then left to the GC.
+type lscq[T any] struct {
+	_     cpu.CacheLinePad
+	head  atomic.Pointer[lscqNode[T]]
+	_     cpu.CacheLinePad
+	tail  atomic.Pointer[lscqNode[T]]
+	_     cpu.CacheLinePad
+	order int
+}
+
+type lscqNode[T any] struct {
+	slots[T, *scq]
+	next atomic.Pointer[lscqNode[T]]
+}
+
+func newLSCQ[T any](order int) *lscq[T] {
+	ret := &lscq[T]{order: order}
+	node := newLSCQNode[T](order)
+	ret.head.Store(node)
+	ret.tail.Store(node)
+
+	return ret
+}
+
+func newLSCQNode[T any](order int) *lscqNode[T] {
+	ret := &lscqNode[T]{}
+	ret.init(newSCQ(order), newSCQ(order))
+
+	return ret
+}
+
+func (q *lscq[T]) offer(elem *T) {
+	for {
+		r := q.tail.Load()
+		if next := r.next.Load(); next != nil {
+			q.tail.CompareAndSwap(r, next)
+			continue
+		}
+		if r.offer(elem) {
+			return
+		}
+		r.aq.finalize()
+		node := newLSCQNode[T](q.order)
+		node.offer(elem)
+		if r.next.CompareAndSwap(nil, node) {
+			q.tail.CompareAndSwap(r, node)
+			return
+		}
+	}
+}
+
+func (q *lscq[T]) poll() (elem *T, ok bool) {
+	for {
+		r := q.head.Load()
+		if elem, ok = r.poll(); ok {
+			return
+		}
+		next := r.next.Load()
+		if next == nil {
+			return nil, false
+		}
+		// r has been finalized before next was linked,
+		// so it is drained once a full scan finds nothing
+		r.aq.reset()
+		if elem, ok = r.poll(); ok {
+			return
+		}
+		q.head.CompareAndSwap(r, next)
+	}
+}
@@ -0,0 +1,103 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package concurrent
+
+import (
+	"sync/atomic"
+
+	"golang.org/x/sys/cpu"
+)
+
+// low contention version
+//
+// Each entry is a 16-bytes aligned pair of words:
+// entry[0] holds the sequence and entry[1] holds the value.
+// An operation claims its entry with a single double word CAS first,
+// then advances head or tail with a CAS that never fails without contention.
+// Operations finding an entry already claimed help advancing the position
+type nblfq struct {
+	entries []uint64
+	mask    uint64
+	_       cpu.CacheLinePad
+	head    atomic.Uint64
+	_       cpu.CacheLinePad
+	tail    atomic.Uint64
+	_       cpu.CacheLinePad
+}
+
+func newNBLFQ(order int) *nblfq {
+	if order < 1 || order > 30 {
+		panic("bad capacity order")
+	}
+	ret := &nblfq{mask: 1<<order - 1}
+	ret.entries = alignedUint128s(1 << order)
+	for i := range 1 << order {
+		ret.entries[2*i] = uint64(i)
+	}
+
+	return ret
+}
+
+func (q *nblfq) offer(elem uint64) bool {
+	for {
+		t := q.tail.Load()
+		e := q.entries[2*(t&q.mask):]
+		seq := atomic.LoadUint64(&e[0])
+		switch d := int64(seq - t); {
+		case d == 0:
+			if cas128(&e[0], [2]uint64{t, 0}, [2]uint64{t + 1, elem}) {
+				q.tail.CompareAndSwap(t, t+1)
+				return true
+			}
+		case d > 0:
+			q.tail.CompareAndSwap(t, t+1)
+		default:
+			if t == q.tail.Load() {
+				return false
+			}
+		}
+	}
+}
+
+func (q *nblfq) poll() (elem uint64, ok bool) {
+	for {
+		h := q.head.Load()
+		e := q.entries[2*(h&q.mask):]
+		seq, val := atomic.LoadUint64(&e[0]), atomic.LoadUint64(&e[1])
+		switch d := int64(seq - (h + 1)); {
+		case d == 0:
+			if cas128(&e[0], [2]uint64{h + 1, val}, [2]uint64{h + q.mask + 1, 0}) {
+				q.head.CompareAndSwap(h, h+1)
+				return val, true
+			}
+		case d > 0:
+			q.head.CompareAndSwap(h, h+1)
+		default:
+			if h == q.head.Load() {
+				return 0, false
+			}
+		}
+	}
+}
+
+// Len returns the approximate number of queued elements
+func (q *nblfq) Len() int {
+	return clampLen(int64(q.tail.Load()-q.head.Load()), int(q.mask+1))
+}
+
+// Cap returns the capacity of the queue
+func (q *nblfq) Cap() int {
+	return int(q.mask + 1)
+}
+
+// IsEmpty reports whether the queue is approximately empty
+func (q *nblfq) IsEmpty() bool {
+	return int64(q.tail.Load()-q.head.Load()) <= 0
+}
+
+// IsFull reports whether the queue is approximately full
+func (q *nblfq) IsFull() bool {
+	return int64(q.tail.Load()-q.head.Load()) >= int64(int(q.mask+1))
+}
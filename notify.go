@@ -0,0 +1,115 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package concurrent
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// eventCount parks goroutines waiting for a queue condition.
+//
+// A waiter registers itself before it checks the condition once more,
+// the opposite side publishes its change before it loads the waiter count,
+// so either the waiter sees the change or the opposite side sees the waiter.
+// notify costs a single atomic load while nobody waits
+type eventCount struct {
+	waiters atomic.Int32
+	mu      sync.Mutex
+	parked  []chan struct{}
+}
+
+// prepare registers a waiter and returns the channel it is woken up by
+func (e *eventCount) prepare() chan struct{} {
+	w := make(chan struct{}, 1)
+	e.mu.Lock()
+	e.parked = append(e.parked, w)
+	e.waiters.Add(1)
+	e.mu.Unlock()
+
+	return w
+}
+
+// cancel unregisters a waiter which does not park anymore.
+// A wake-up which has already been handed to w is passed on to the next waiter
+func (e *eventCount) cancel(w chan struct{}) {
+	e.mu.Lock()
+	i := slices.Index(e.parked, w)
+	if i >= 0 {
+		e.parked = slices.Delete(e.parked, i, i+1)
+		e.waiters.Add(-1)
+	}
+	e.mu.Unlock()
+	if i < 0 {
+		e.notify()
+	}
+}
+
+// notify wakes up the longest waiting goroutine if any
+func (e *eventCount) notify() {
+	if e.waiters.Load() == 0 {
+		return
+	}
+	e.mu.Lock()
+	if len(e.parked) > 0 {
+		w := e.parked[0]
+		e.parked[0] = nil
+		e.parked = e.parked[1:]
+		e.waiters.Add(-1)
+		w <- struct{}{}
+	}
+	e.mu.Unlock()
+}
+
+// broadcast wakes up all waiting goroutines
+func (e *eventCount) broadcast() {
+	e.mu.Lock()
+	for _, w := range e.parked {
+		w <- struct{}{}
+	}
+	e.waiters.Add(-int32(len(e.parked)))
+	e.parked = nil
+	e.mu.Unlock()
+}
+
+// wait calls try until it reports done and parks in between.
+// It returns ctx.Err() if the context is done before
+func (e *eventCount) wait(ctx context.Context, try func() bool) error {
+	for !try() {
+		w := e.prepare()
+		if try() {
+			e.cancel(w)
+			return nil
+		}
+		select {
+		case <-w:
+		case <-ctx.Done():
+			e.cancel(w)
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+// notifier holds the wait queues of a queue.
+// Consumers wait on notEmpty and are woken by Enqueue,
+// producers wait on notFull and are woken by Dequeue
+type notifier struct {
+	notEmpty eventCount
+	notFull  eventCount
+}
+
+func (n *notifier) parking() *notifier {
+	return n
+}
+
+// parker is implemented by the queues which wake up their waiters,
+// EnqueueWait and DequeueWait park on them instead of polling
+type parker interface {
+	parking() *notifier
+}
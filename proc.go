@@ -0,0 +1,20 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package concurrent
+
+import (
+	_ "unsafe"
+)
+
+// procPin pins the current goroutine to its P, disables preemption
+// and returns the id of the P
+//
+//go:linkname procPin runtime.procPin
+func procPin() int
+
+// procUnpin undoes procPin
+//
+//go:linkname procUnpin runtime.procUnpin
+func procUnpin()
@@ -0,0 +1,108 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package concurrent_test
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"code.hybscloud.com/concurrent"
+)
+
+// reclaimNode is a node of the structures in the memory reclamation tests
+type reclaimNode struct {
+	next  atomic.Pointer[reclaimNode]
+	value atomic.Int64
+	live  atomic.Bool
+}
+
+// reclaimFreeList recycles reclaimed nodes through an ABA-safe stack,
+// so a node may be pushed again as soon as it has been reclaimed
+type reclaimFreeList struct {
+	free *concurrent.Stack[reclaimNode]
+}
+
+func newReclaimFreeList() reclaimFreeList {
+	return reclaimFreeList{free: concurrent.NewStack[reclaimNode]()}
+}
+
+func (l reclaimFreeList) node(v int64) *reclaimNode {
+	n, err := l.free.TryPop()
+	if err != nil {
+		n = &reclaimNode{}
+	}
+	n.next.Store(nil)
+	n.value.Store(v)
+	n.live.Store(true)
+
+	return n
+}
+
+func (l reclaimFreeList) reclaim(n *reclaimNode) {
+	n.live.Store(false)
+	_ = l.free.Push(n)
+}
+
+// reclaimStructure is a structure of plain pointers which recycles
+// popped nodes through a reclaimFreeList.
+// Every goroutine operates it with a handle of type H of the reclamation scheme
+type reclaimStructure[H any] interface {
+	acquire() H
+	release(h H)
+	push(h H, v int64)
+	pop(t *testing.T, h H) (int64, bool)
+}
+
+// testReclaimStructure pushes and pops concurrently with n goroutines,
+// it relies on the reclamation scheme alone to avoid ABA and use-after-reuse
+func testReclaimStructure[H any](t *testing.T, n int, s reclaimStructure[H]) {
+	const m = 1 << 13
+	pushed, popped := atomic.Int64{}, atomic.Int64{}
+	wg := sync.WaitGroup{}
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h := s.acquire()
+			defer s.release(h)
+			for j := range m {
+				if (i+j)%2 == 0 {
+					s.push(h, int64(j))
+					pushed.Add(int64(j))
+				} else if v, ok := s.pop(t, h); ok {
+					popped.Add(v)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	h := s.acquire()
+	for {
+		v, ok := s.pop(t, h)
+		if !ok {
+			break
+		}
+		popped.Add(v)
+	}
+	s.release(h)
+	if pushed.Load() != popped.Load() {
+		t.Errorf("popped sum expected %v but got %v", pushed.Load(), popped.Load())
+	}
+}
+
+// expectLive reports an error if any of nodes has been reclaimed while
+// the caller still protects them. It yields first to widen the window
+// in which other goroutines pop and reuse the nodes
+func expectLive(t *testing.T, nodes ...*reclaimNode) {
+	runtime.Gosched()
+	for _, n := range nodes {
+		if !n.live.Load() {
+			t.Error("node reused while it is still protected")
+			return
+		}
+	}
+}
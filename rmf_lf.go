@@ -93,7 +93,176 @@ func (lf *rmfLF) poll() (elem uintptr, ok bool) {
 	}
 }
 
+// offerBatch fills a range of positions before it publishes them
+// with a single update of offers, concurrent offers help to publish
+// the filled positions. It returns the number of offered elements
+func (lf *rmfLF) offerBatch(elems []uintptr) int {
+	n := 0
+	sw := SpinWait{}
+	for n < len(elems) {
+		o, p := lf.offers.Load(), lf.polls.Load()
+		if o != lf.offers.Load() {
+			sw.Once()
+			continue
+		}
+		k := min(uint64(len(elems)-n), p+lf.capacity-o)
+		if k == 0 {
+			break
+		}
+		j := uint64(0)
+		for ; j < k; j++ {
+			entry := lf.entry((o + j) & (lf.capacity - 1))
+			round := ((o + j) >> lf.order) & (rmfLFNilFlag - 1)
+			if !atomic.CompareAndSwapUintptr(&lf.entries[entry], rmfLFNilFlag|uintptr(round), elems[n+int(j)]) {
+				break
+			}
+		}
+		lf.publish(&lf.offers, o, j)
+		n += int(j)
+		if j < k {
+			sw.Once()
+		}
+	}
+
+	return n
+}
+
+// pollBatch consumes a range of positions before it publishes them
+// with a single update of polls. It returns the number of polled elements
+func (lf *rmfLF) pollBatch(elems []uintptr) int {
+	n := 0
+	sw := SpinWait{}
+	for n < len(elems) {
+		p, o := lf.polls.Load(), lf.offers.Load()
+		if p != lf.polls.Load() {
+			sw.Once()
+			continue
+		}
+		k := min(uint64(len(elems)-n), o-p)
+		if k == 0 {
+			break
+		}
+		j := uint64(0)
+		for ; j < k; j++ {
+			entry := lf.entry((p + j) & (lf.capacity - 1))
+			e := atomic.LoadUintptr(&lf.entries[entry])
+			if lf.polls.Load() > p+j {
+				break
+			}
+			nextRound := uintptr(((p+j)>>lf.order)+1) & (rmfLFNilFlag - 1)
+			if e == rmfLFNilFlag|nextRound || !atomic.CompareAndSwapUintptr(&lf.entries[entry], e, rmfLFNilFlag|nextRound) {
+				break
+			}
+			elems[n+int(j)] = e
+		}
+		lf.publish(&lf.polls, p, j)
+		n += int(j)
+		if j < k {
+			sw.Once()
+		}
+	}
+
+	return n
+}
+
+// publish advances counter from pos to at least pos+n,
+// or by one to help the operation which has won the position pos
+func (lf *rmfLF) publish(counter *atomic.Uint64, pos, n uint64) {
+	if n == 0 {
+		counter.CompareAndSwap(pos, pos+1)
+		return
+	}
+	for c := counter.Load(); c < pos+n; c = counter.Load() {
+		if counter.CompareAndSwap(c, pos+n) {
+			return
+		}
+	}
+}
+
 func (lf *rmfLF) entry(index uint64) uint64 {
 	p, q := index>>rmfLFModuleBit, index&rmfLFModuleMask
 	return q*lf.indexSkip + p
 }
+
+// rmfLFIndex adapts rmfLF to a ring of slot indices.
+// The indices circulate through the ring again and again, so a poller
+// which loaded an entry before it was taken and refilled with the same index
+// would take the entry of a later round. Every offer tags the index with
+// a new generation of its slot, the generations of an index are only
+// advanced by the goroutine owning the index
+type rmfLFIndex struct {
+	*rmfLF
+	gens []uint32
+}
+
+const (
+	rmfLFIndexGenBit  = 32
+	rmfLFIndexGenMask = 1<<(63-rmfLFIndexGenBit) - 1
+	rmfLFIndexMask    = 1<<rmfLFIndexGenBit - 1
+)
+
+func newRmfLFIndex(order int) rmfLFIndex {
+	ret := rmfLFIndex{rmfLF: newRmfLF(order)}
+	ret.gens = make([]uint32, ret.capacity)
+
+	return ret
+}
+
+func (r rmfLFIndex) offer(index uint64) bool {
+	return r.rmfLF.offer(r.tag(uintptr(index)))
+}
+
+func (r rmfLFIndex) poll() (index uint64, ok bool) {
+	e, ok := r.rmfLF.poll()
+
+	return uint64(e & rmfLFIndexMask), ok
+}
+
+// offerBatch works as rmfLF.offerBatch and leaves indices untagged
+func (r rmfLFIndex) offerBatch(indices []uintptr) int {
+	for j, i := range indices {
+		indices[j] = r.tag(i)
+	}
+	n := r.rmfLF.offerBatch(indices)
+	for j, e := range indices {
+		indices[j] = e & rmfLFIndexMask
+	}
+
+	return n
+}
+
+// pollBatch works as rmfLF.pollBatch
+func (r rmfLFIndex) pollBatch(indices []uintptr) int {
+	n := r.rmfLF.pollBatch(indices)
+	for j, e := range indices[:n] {
+		indices[j] = e & rmfLFIndexMask
+	}
+
+	return n
+}
+
+// tag returns the index tagged with its next generation
+func (r rmfLFIndex) tag(index uintptr) uintptr {
+	r.gens[index]++
+	return uintptr(r.gens[index]&rmfLFIndexGenMask)<<rmfLFIndexGenBit | index
+}
+
+// Len returns the approximate number of queued elements
+func (lf *rmfLF) Len() int {
+	return clampLen(int64(lf.offers.Load()-lf.polls.Load()), int(lf.capacity))
+}
+
+// Cap returns the capacity of the queue
+func (lf *rmfLF) Cap() int {
+	return int(lf.capacity)
+}
+
+// IsEmpty reports whether the queue is approximately empty
+func (lf *rmfLF) IsEmpty() bool {
+	return int64(lf.offers.Load()-lf.polls.Load()) <= 0
+}
+
+// IsFull reports whether the queue is approximately full
+func (lf *rmfLF) IsFull() bool {
+	return int64(lf.offers.Load()-lf.polls.Load()) >= int64(int(lf.capacity))
+}
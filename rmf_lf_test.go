@@ -0,0 +1,49 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package concurrent
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRmfLFIndexRecycle(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(8))
+	// the same few indices circulate between the rings all the time,
+	// an untagged ring hands out empty entries and taken indices
+	aq, fq := newRmfLFIndex(2), newRmfLFIndex(2)
+	for i := range uint64(aq.capacity) {
+		fq.offer(i)
+	}
+	owned := make([]atomic.Bool, aq.capacity)
+	take := func(from, to rmfLFIndex) bool {
+		i, ok := from.poll()
+		if !ok {
+			return true
+		}
+		if i >= uint64(len(owned)) || !owned[i].CompareAndSwap(false, true) {
+			t.Errorf("poll expected an index which is not taken but got %x", i)
+			return false
+		}
+		owned[i].Store(false)
+		to.offer(i)
+		return true
+	}
+	wg := sync.WaitGroup{}
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 1 << 16 {
+				if !take(fq, aq) || !take(aq, fq) {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -5,12 +5,24 @@
 package concurrent
 
 import (
+	"sync/atomic"
+
 	"golang.org/x/sys/cpu"
 )
 
 // double word CAS version
+//
+// Each entry is a 16-bytes aligned pair of words:
+// entry[0] holds cycle<<2 | safe<<1 | occupied and
+// entry[1] holds the value itself, so the value may use the full 64-bit range.
+// The ring has 2n entries for n elements, which together with
+// the threshold counter makes the queue livelock-free.
+// Like in the paper offer does not check whether the ring is full,
+// the callers keep at most n elements in the ring with a free index ring.
+// The top bit of tail is set once the ring is finalized,
+// after which every offer fails so that LSCQ can link a new ring.
 type scq struct {
-	entries   [][]uint64
+	entries   []uint64
 	_         cpu.CacheLinePad
 	head      uint64
 	_         cpu.CacheLinePad
@@ -20,4 +32,164 @@ type scq struct {
 
 	n      uint64
 	module uint64
+	order  int
+}
+
+const (
+	scqOccupied = 1 << 0
+	scqSafe     = 1 << 1
+	scqCycleBit = 2
+
+	scqFinalized = 1 << 63
+)
+
+func newSCQ(order int) *scq {
+	if order < 1 || order > 30 {
+		panic("bad capacity order")
+	}
+	ret := &scq{
+		n:     1 << order,
+		order: order,
+	}
+	ret.module = 1 << max(0, ret.order+1-rmfLFModuleBit)
+	ret.head = 2 * ret.n
+	ret.tail = 2 * ret.n
+	ret.threshold = -1
+
+	ret.entries = alignedUint128s(int(2 * ret.n))
+	for i := 0; i < len(ret.entries); i += 2 {
+		ret.entries[i] = scqSafe
+	}
+
+	return ret
+}
+
+func (q *scq) offer(elem uint64) bool {
+	for {
+		t := atomic.AddUint64(&q.tail, 1) - 1
+		if t&scqFinalized != 0 {
+			return false
+		}
+		tCycle := q.cycle(t)
+		e := q.entries[2*q.entry(t):]
+		for {
+			e0, e1 := atomic.LoadUint64(&e[0]), atomic.LoadUint64(&e[1])
+			if e0>>scqCycleBit >= tCycle || e0&scqOccupied != 0 {
+				break
+			}
+			if e0&scqSafe == 0 && atomic.LoadUint64(&q.head) > t {
+				break
+			}
+			next := tCycle<<scqCycleBit | scqSafe | scqOccupied
+			if cas128(&e[0], [2]uint64{e0, e1}, [2]uint64{next, elem}) {
+				if atomic.LoadInt64(&q.threshold) != q.thresholdMax() {
+					atomic.StoreInt64(&q.threshold, q.thresholdMax())
+				}
+				return true
+			}
+		}
+	}
+}
+
+func (q *scq) poll() (elem uint64, ok bool) {
+	if atomic.LoadInt64(&q.threshold) < 0 {
+		return 0, false
+	}
+	for {
+		h := atomic.AddUint64(&q.head, 1) - 1
+		hCycle := q.cycle(h)
+		e := q.entries[2*q.entry(h):]
+		for {
+			e0, e1 := atomic.LoadUint64(&e[0]), atomic.LoadUint64(&e[1])
+			eCycle := e0 >> scqCycleBit
+			if eCycle == hCycle && e0&scqOccupied != 0 {
+				if cas128(&e[0], [2]uint64{e0, e1}, [2]uint64{e0 &^ scqOccupied, 0}) {
+					return e1, true
+				}
+				continue
+			}
+			if eCycle >= hCycle {
+				break
+			}
+			// mark the entry unsafe if it is occupied by a previous cycle,
+			// otherwise advance its cycle so that late enqueuers skip it
+			next := e0 &^ scqSafe
+			if e0&scqOccupied == 0 {
+				next = hCycle<<scqCycleBit | e0&scqSafe
+			}
+			if cas128(&e[0], [2]uint64{e0, e1}, [2]uint64{next, e1}) {
+				break
+			}
+		}
+		t := atomic.LoadUint64(&q.tail)
+		if t&^scqFinalized <= h+1 {
+			q.catchup(t, h+1)
+			atomic.AddInt64(&q.threshold, -1)
+			return 0, false
+		}
+		if atomic.AddInt64(&q.threshold, -1) < 0 {
+			return 0, false
+		}
+	}
+}
+
+func (q *scq) catchup(tail, head uint64) {
+	for !atomic.CompareAndSwapUint64(&q.tail, tail, head|tail&scqFinalized) {
+		head, tail = atomic.LoadUint64(&q.head), atomic.LoadUint64(&q.tail)
+		if tail&^scqFinalized >= head {
+			return
+		}
+	}
+}
+
+// finalize makes every following offer fail
+func (q *scq) finalize() {
+	OrUint64(&q.tail, scqFinalized)
+}
+
+// reset makes the next poll scan the ring even if it looked empty before
+func (q *scq) reset() {
+	atomic.StoreInt64(&q.threshold, q.thresholdMax())
+}
+
+// cycle returns the cycle of the given head or tail position
+func (q *scq) cycle(pos uint64) uint64 {
+	return pos >> (q.order + 1)
+}
+
+func (q *scq) thresholdMax() int64 {
+	return int64(3*q.n - 1)
+}
+
+func (q *scq) entry(index uint64) uint64 {
+	index &= 2*q.n - 1
+	p, r := index>>rmfLFModuleBit, index&rmfLFModuleMask
+	return r*q.module + p
+}
+
+// Len returns the approximate number of queued elements
+func (q *scq) Len() int {
+	return clampLen(q.len(), int(q.n))
+}
+
+// Cap returns the capacity of the queue
+func (q *scq) Cap() int {
+	return int(q.n)
+}
+
+// IsEmpty reports whether the queue is approximately empty
+func (q *scq) IsEmpty() bool {
+	return q.len() <= 0
+}
+
+// IsFull reports whether the queue is approximately full
+func (q *scq) IsFull() bool {
+	return q.len() >= int64(q.n)
+}
+
+// len returns the distance from head to tail, positions skipped by
+// failed attempts are counted until a dequeuer passes them
+func (q *scq) len() int64 {
+	h := atomic.LoadUint64(&q.head)
+	return int64(atomic.LoadUint64(&q.tail)&^scqFinalized - h)
 }
@@ -5,10 +5,20 @@
 package concurrent
 
 import (
+	"math"
+	"sync/atomic"
+
 	"golang.org/x/sys/cpu"
 )
 
 // half-address size version
+//
+// Each entry is a single word:
+// the high half holds the cycle (position with the low order+1 bits cleared)
+// with the safe flag in its lowest bit, and the low half holds the index.
+// An empty entry holds scqHalfBottom as its index.
+// Positions wrap around, so cycles are compared with signed distances.
+// The ring has 2n entries for n elements like scq, that is 16 bytes per element.
 type scqHalf struct {
 	entries   []uint64
 	_         cpu.CacheLinePad
@@ -17,7 +27,162 @@ type scqHalf struct {
 	tail      uint32
 	_         cpu.CacheLinePad
 	threshold int32
+	_         cpu.CacheLinePad
+	items     int32
 
 	n      uint32
 	module uint32
+	order  int
+}
+
+const (
+	scqHalfBottom   = math.MaxUint32
+	scqHalfSafe     = 1 << 32
+	scqHalfMaxOrder = 24
+)
+
+func newSCQHalf(order int) *scqHalf {
+	if order < 1 || order > scqHalfMaxOrder {
+		panic("bad capacity order")
+	}
+	ret := &scqHalf{
+		n:     1 << order,
+		order: order,
+	}
+	ret.module = 1 << max(0, ret.order+1-rmfLFModuleBit)
+	ret.head = 2 * ret.n
+	ret.tail = 2 * ret.n
+	ret.threshold = -1
+
+	ret.entries = make([]uint64, 2*ret.n)
+	for i := range ret.entries {
+		ret.entries[i] = scqHalfSafe | scqHalfBottom
+	}
+
+	return ret
+}
+
+func (q *scqHalf) offer(index uint32) bool {
+	if index == scqHalfBottom {
+		panic("bad index")
+	}
+	if atomic.AddInt32(&q.items, 1) > int32(q.n) {
+		atomic.AddInt32(&q.items, -1)
+		return false
+	}
+	for {
+		t := atomic.AddUint32(&q.tail, 1) - 1
+		tCycle := q.cycle(t)
+		e := &q.entries[q.entry(t)]
+		for {
+			ent := atomic.LoadUint64(e)
+			if !q.before(q.entryCycle(ent), tCycle) || uint32(ent) != scqHalfBottom {
+				break
+			}
+			if ent&scqHalfSafe == 0 && q.before(t, atomic.LoadUint32(&q.head)) {
+				break
+			}
+			next := uint64(tCycle)<<32 | scqHalfSafe | uint64(index)
+			if atomic.CompareAndSwapUint64(e, ent, next) {
+				if atomic.LoadInt32(&q.threshold) != q.thresholdMax() {
+					atomic.StoreInt32(&q.threshold, q.thresholdMax())
+				}
+				return true
+			}
+		}
+	}
+}
+
+func (q *scqHalf) poll() (index uint32, ok bool) {
+	if atomic.LoadInt32(&q.threshold) < 0 {
+		return 0, false
+	}
+	for {
+		h := atomic.AddUint32(&q.head, 1) - 1
+		hCycle := q.cycle(h)
+		e := &q.entries[q.entry(h)]
+		for {
+			ent := atomic.LoadUint64(e)
+			eCycle := q.entryCycle(ent)
+			if eCycle == hCycle && uint32(ent) != scqHalfBottom {
+				OrUint64(e, scqHalfBottom)
+				atomic.AddInt32(&q.items, -1)
+				return uint32(ent), true
+			}
+			if !q.before(eCycle, hCycle) {
+				break
+			}
+			// mark the entry unsafe if it is occupied by a previous cycle,
+			// otherwise advance its cycle so that late enqueuers skip it
+			next := ent &^ scqHalfSafe
+			if uint32(ent) == scqHalfBottom {
+				next = uint64(hCycle)<<32 | ent&scqHalfSafe | scqHalfBottom
+			}
+			if atomic.CompareAndSwapUint64(e, ent, next) {
+				break
+			}
+		}
+		t := atomic.LoadUint32(&q.tail)
+		if !q.before(h+1, t) {
+			q.catchup(t, h+1)
+			atomic.AddInt32(&q.threshold, -1)
+			return 0, false
+		}
+		if atomic.AddInt32(&q.threshold, -1) < 0 {
+			return 0, false
+		}
+	}
+}
+
+func (q *scqHalf) catchup(tail, head uint32) {
+	for !atomic.CompareAndSwapUint32(&q.tail, tail, head) {
+		head, tail = atomic.LoadUint32(&q.head), atomic.LoadUint32(&q.tail)
+		if !q.before(tail, head) {
+			return
+		}
+	}
+}
+
+// before reports whether position a precedes position b
+func (q *scqHalf) before(a, b uint32) bool {
+	return int32(a-b) < 0
+}
+
+// cycle returns the cycle of the given head or tail position
+func (q *scqHalf) cycle(pos uint32) uint32 {
+	return pos &^ (2*q.n - 1)
+}
+
+func (q *scqHalf) entryCycle(ent uint64) uint32 {
+	return uint32(ent>>32) &^ (2*q.n - 1)
+}
+
+func (q *scqHalf) thresholdMax() int32 {
+	return int32(3*q.n - 1)
+}
+
+func (q *scqHalf) entry(index uint32) uint32 {
+	index &= 2*q.n - 1
+	p, r := index>>rmfLFModuleBit, index&rmfLFModuleMask
+	return r*q.module + p
+}
+
+// Len returns the approximate number of queued elements
+func (q *scqHalf) Len() int {
+	return clampLen(int64(atomic.LoadInt32(&q.items)), int(q.n))
+}
+
+// Cap returns the capacity of the queue
+func (q *scqHalf) Cap() int {
+	return int(q.n)
+}
+
+// IsEmpty reports whether the queue is approximately empty
+func (q *scqHalf) IsEmpty() bool {
+	return int64(atomic.LoadInt32(&q.items)) <= 0
+}
+
+// IsFull reports whether the queue is approximately full
+func (q *scqHalf) IsFull() bool {
+	return int64(atomic.LoadInt32(&q.items)) >= int64(int(q.n))
 }
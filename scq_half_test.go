@@ -0,0 +1,42 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package concurrent
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSCQHalfWrapAround(t *testing.T) {
+	q := newSCQHalf(3)
+	start := uint32(math.MaxUint32 - 16*q.n + 1)
+	q.head, q.tail = start, start
+	for i := range q.entries {
+		q.entries[i] = uint64(q.cycle(start)-2*q.n)<<32 | scqHalfSafe | scqHalfBottom
+	}
+	for i := range uint32(1 << 10) {
+		if !q.offer(i) {
+			t.Errorf("offer %d expected success but failed", i)
+			return
+		}
+		if i%2 == 0 {
+			continue
+		}
+		for j := i - 1; j <= i; j++ {
+			index, ok := q.poll()
+			if !ok {
+				t.Errorf("poll expected %d but queue is empty", j)
+				return
+			}
+			if index != j {
+				t.Errorf("poll expected %d but got %d", j, index)
+				return
+			}
+		}
+	}
+	if _, ok := q.poll(); ok {
+		t.Errorf("poll expected empty queue")
+	}
+}
@@ -0,0 +1,81 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package concurrent
+
+import (
+	"sync/atomic"
+)
+
+// indexRing is a bounded ring of slot indices
+type indexRing interface {
+	offer(index uint64) bool
+	poll() (index uint64, ok bool)
+	Sizer
+}
+
+// slot array of pointers
+//
+// The elements stay in a slot array which the GC sees, only the indices
+// of allocated and free slots circulate through the aq and fq rings,
+// so a ring of words may back a queue of pointers.
+// The fq ring holds every index initially
+type slots[T any, R indexRing] struct {
+	entries []atomic.Pointer[T]
+	aq, fq  R
+}
+
+func (s *slots[T, R]) init(aq, fq R) {
+	s.entries = make([]atomic.Pointer[T], aq.Cap())
+	s.aq, s.fq = aq, fq
+	for i := range uint64(len(s.entries)) {
+		s.fq.offer(i)
+	}
+}
+
+func (s *slots[T, R]) offer(elem *T) bool {
+	i, ok := s.fq.poll()
+	if !ok {
+		return false
+	}
+	s.entries[i].Store(elem)
+	if !s.aq.offer(i) {
+		s.entries[i].Store(nil)
+		s.fq.offer(i)
+		return false
+	}
+
+	return true
+}
+
+func (s *slots[T, R]) poll() (elem *T, ok bool) {
+	i, ok := s.aq.poll()
+	if !ok {
+		return nil, false
+	}
+	elem = s.entries[i].Swap(nil)
+	s.fq.offer(i)
+
+	return elem, true
+}
+
+// Len returns the approximate number of queued elements
+func (s *slots[T, R]) Len() int {
+	return s.aq.Len()
+}
+
+// Cap returns the capacity of the queue
+func (s *slots[T, R]) Cap() int {
+	return len(s.entries)
+}
+
+// IsEmpty reports whether the queue is approximately empty
+func (s *slots[T, R]) IsEmpty() bool {
+	return s.aq.IsEmpty()
+}
+
+// IsFull reports whether the queue is approximately full
+func (s *slots[T, R]) IsFull() bool {
+	return s.aq.IsFull()
+}
@@ -0,0 +1,252 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package concurrent
+
+import (
+	"context"
+	"math/bits"
+	"sync/atomic"
+
+	"golang.org/x/sys/cpu"
+)
+
+// Stack represents lock-free LIFO stack (Treiber stack).
+// The head is a pair of node index and tag updated with double word CAS,
+// so recycled nodes never cause ABA problems
+type Stack[T any] struct {
+	stack[*T]
+}
+
+// NewStack creates a new unbounded LIFO stack
+func NewStack[T any]() *Stack[T] {
+	ret := &Stack[T]{}
+	ret.init(0)
+
+	return ret
+}
+
+// NewBoundedStack creates a new LIFO stack which holds at most capacity items
+func NewBoundedStack[T any](capacity int) *Stack[T] {
+	if capacity < 1 {
+		panic("bad capacity")
+	}
+	ret := &Stack[T]{}
+	ret.init(uint64(capacity))
+
+	return ret
+}
+
+// Push pushes the given item onto the stack.
+// if the stack is bounded and full, ErrTemporaryUnavailable will be returned
+func (s *Stack[T]) Push(elem *T) error {
+	if !s.push(elem) {
+		return ErrTemporaryUnavailable
+	}
+
+	return nil
+}
+
+// TryPop pops the most recently pushed item.
+// if the stack is empty, ErrTemporaryUnavailable will be returned
+func (s *Stack[T]) TryPop() (elem *T, err error) {
+	elem, ok := s.pop()
+	if !ok {
+		return nil, ErrTemporaryUnavailable
+	}
+
+	return
+}
+
+// Pop pops the most recently pushed item,
+// it blocks while the stack is empty
+func (s *Stack[T]) Pop() *T {
+	elem, _ := s.PopContext(context.Background())
+
+	return elem
+}
+
+// PopContext pops the most recently pushed item,
+// it blocks while the stack is empty and returns ctx.Err() once the context is done
+func (s *Stack[T]) PopContext(ctx context.Context) (elem *T, err error) {
+	err = s.notEmpty.wait(ctx, func() bool {
+		var ok bool
+		elem, ok = s.pop()
+		return ok
+	})
+
+	return
+}
+
+// StackIndirect represents lock-free LIFO stack of uintptr values
+// such as indices of a free-list
+type StackIndirect struct {
+	stack[uintptr]
+}
+
+// NewStackIndirect creates a new unbounded LIFO stack of uintptr values
+func NewStackIndirect() *StackIndirect {
+	ret := &StackIndirect{}
+	ret.init(0)
+
+	return ret
+}
+
+// NewBoundedStackIndirect creates a new LIFO stack of uintptr values
+// which holds at most capacity items
+func NewBoundedStackIndirect(capacity int) *StackIndirect {
+	if capacity < 1 {
+		panic("bad capacity")
+	}
+	ret := &StackIndirect{}
+	ret.init(uint64(capacity))
+
+	return ret
+}
+
+// Push pushes the given value onto the stack.
+// if the stack is bounded and full, ErrTemporaryUnavailable will be returned
+func (s *StackIndirect) Push(elem uintptr) error {
+	if !s.push(elem) {
+		return ErrTemporaryUnavailable
+	}
+
+	return nil
+}
+
+// TryPop pops the most recently pushed value.
+// if the stack is empty, ErrTemporaryUnavailable will be returned
+func (s *StackIndirect) TryPop() (elem uintptr, err error) {
+	elem, ok := s.pop()
+	if !ok {
+		return 0, ErrTemporaryUnavailable
+	}
+
+	return
+}
+
+// Pop pops the most recently pushed value,
+// it blocks while the stack is empty
+func (s *StackIndirect) Pop() uintptr {
+	elem, _ := s.PopContext(context.Background())
+
+	return elem
+}
+
+// PopContext pops the most recently pushed value,
+// it blocks while the stack is empty and returns ctx.Err() once the context is done
+func (s *StackIndirect) PopContext(ctx context.Context) (elem uintptr, err error) {
+	err = s.notEmpty.wait(ctx, func() bool {
+		var ok bool
+		elem, ok = s.pop()
+		return ok
+	})
+
+	return
+}
+
+// Treiber stack of values
+//
+// Nodes live in chunks which are never freed while the stack is alive,
+// a node index is allocated by bumping alloc or by popping the free list.
+// Both top and free hold {node index + 1, tag}, the tag is incremented
+// by every successful CAS. Pushers store next atomically after the element
+// so that poppers loading next observe the element
+type stack[V any] struct {
+	top      []uint64
+	_        cpu.CacheLinePad
+	free     []uint64
+	_        cpu.CacheLinePad
+	alloc    atomic.Uint64
+	_        cpu.CacheLinePad
+	capacity uint64
+	chunks   [stackChunks]atomic.Pointer[[]stackNode[V]]
+	notifier
+}
+
+type stackNode[V any] struct {
+	next uint64
+	elem V
+}
+
+const (
+	stackChunkBit = 6
+	stackChunks   = 64 - stackChunkBit
+)
+
+func (s *stack[V]) init(capacity uint64) {
+	s.top = alignedUint128s(1)
+	s.free = alignedUint128s(1)
+	s.capacity = capacity
+}
+
+func (s *stack[V]) push(elem V) bool {
+	i, ok := s.popNode(s.free)
+	if !ok {
+		i = s.alloc.Add(1) - 1
+		if s.capacity > 0 && i >= s.capacity {
+			s.alloc.Add(^uint64(0))
+			return false
+		}
+	}
+	n := s.node(i)
+	n.elem = elem
+	s.pushNode(s.top, i)
+	s.notEmpty.notify()
+
+	return true
+}
+
+func (s *stack[V]) pop() (elem V, ok bool) {
+	i, ok := s.popNode(s.top)
+	if !ok {
+		return elem, false
+	}
+	n := s.node(i)
+	var zero V
+	elem, n.elem = n.elem, zero
+	s.pushNode(s.free, i)
+
+	return elem, true
+}
+
+func (s *stack[V]) pushNode(list []uint64, i uint64) {
+	n := s.node(i)
+	for {
+		head, tag := atomic.LoadUint64(&list[0]), atomic.LoadUint64(&list[1])
+		atomic.StoreUint64(&n.next, head)
+		if cas128(&list[0], [2]uint64{head, tag}, [2]uint64{i + 1, tag + 1}) {
+			return
+		}
+	}
+}
+
+func (s *stack[V]) popNode(list []uint64) (i uint64, ok bool) {
+	for {
+		head, tag := atomic.LoadUint64(&list[0]), atomic.LoadUint64(&list[1])
+		if head == 0 {
+			return 0, false
+		}
+		// the node may have been popped and pushed again meanwhile,
+		// the tag makes the CAS fail in that case
+		next := atomic.LoadUint64(&s.node(head - 1).next)
+		if cas128(&list[0], [2]uint64{head, tag}, [2]uint64{next, tag + 1}) {
+			return head - 1, true
+		}
+	}
+}
+
+// node returns the node of index i, chunk k holds 1<<(stackChunkBit+k) nodes
+func (s *stack[V]) node(i uint64) *stackNode[V] {
+	i += 1 << stackChunkBit
+	k := bits.Len64(i) - 1 - stackChunkBit
+	chunk := s.chunks[k].Load()
+	if chunk == nil {
+		c := make([]stackNode[V], 1<<(stackChunkBit+k))
+		s.chunks[k].CompareAndSwap(nil, &c)
+		chunk = s.chunks[k].Load()
+	}
+
+	return &(*chunk)[i-1<<(stackChunkBit+k)]
+}
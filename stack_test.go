@@ -0,0 +1,185 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package concurrent_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"code.hybscloud.com/concurrent"
+)
+
+func TestStack(t *testing.T) {
+	t.Run("lifo", func(t *testing.T) {
+		s := concurrent.NewStack[int]()
+		_, err := s.TryPop()
+		if err != concurrent.ErrTemporaryUnavailable {
+			t.Errorf("try pop expected ErrTemporaryUnavailable but got %v", err)
+			return
+		}
+		items := make([]int, 200)
+		for i := range items {
+			items[i] = i
+			err = s.Push(&items[i])
+			if err != nil {
+				t.Errorf("push expected <nil> but got %v", err)
+				return
+			}
+		}
+		for i := len(items) - 1; i >= 0; i-- {
+			elem, err := s.TryPop()
+			if err != nil || *elem != i {
+				t.Errorf("try pop expected %v, <nil> but got %v, %v", i, elem, err)
+				return
+			}
+		}
+	})
+
+	t.Run("bounded", func(t *testing.T) {
+		s := concurrent.NewBoundedStack[int](3)
+		items := []int{1, 2, 3, 4}
+		for i := range 3 {
+			_ = s.Push(&items[i])
+		}
+		err := s.Push(&items[3])
+		if err != concurrent.ErrTemporaryUnavailable {
+			t.Errorf("push expected ErrTemporaryUnavailable but got %v", err)
+			return
+		}
+		_, _ = s.TryPop()
+		err = s.Push(&items[3])
+		if err != nil {
+			t.Errorf("push expected <nil> but got %v", err)
+			return
+		}
+		elem := s.Pop()
+		if *elem != 4 {
+			t.Errorf("pop expected 4 but got %v", *elem)
+		}
+		expectPanic(t, "bad capacity", func() { concurrent.NewBoundedStack[int](0) })
+	})
+
+	t.Run("pop blocks", func(t *testing.T) {
+		s := concurrent.NewStack[int]()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		defer cancel()
+		_, err := s.PopContext(ctx)
+		if err != context.DeadlineExceeded {
+			t.Errorf("pop context expected DeadlineExceeded but got %v", err)
+			return
+		}
+		item := 100
+		go func() {
+			time.Sleep(time.Millisecond)
+			_ = s.Push(&item)
+		}()
+		if elem := s.Pop(); elem != &item {
+			t.Errorf("pop expected %v but got %v", &item, elem)
+		}
+	})
+
+	for _, bounded := range []bool{false, true} {
+		for _, n := range []int{1, 4, 16} {
+			t.Run(fmt.Sprintf("%d pushers %d poppers bounded=%v", n, n, bounded), func(t *testing.T) {
+				const m = 1 << 12
+				s := concurrent.NewStack[int64]()
+				if bounded {
+					s = concurrent.NewBoundedStack[int64](8)
+				}
+				items := make([]int64, n*m)
+				sum := atomic.Int64{}
+				wg := sync.WaitGroup{}
+				for i := range n {
+					wg.Add(2)
+					go func() {
+						defer wg.Done()
+						for j := range m {
+							elem := &items[i*m+j]
+							*elem = int64(j)
+							for s.Push(elem) != nil {
+								time.Sleep(time.Microsecond)
+							}
+						}
+					}()
+					go func() {
+						defer wg.Done()
+						for range m {
+							sum.Add(*s.Pop())
+						}
+					}()
+				}
+				wg.Wait()
+				if expected := int64(n * m * (m - 1) / 2); sum.Load() != expected {
+					t.Errorf("sum expected %v but got %v", expected, sum.Load())
+				}
+			})
+		}
+	}
+}
+
+func TestStackIndirect(t *testing.T) {
+	s := concurrent.NewBoundedStackIndirect(64)
+	for i := range 64 {
+		_ = s.Push(uintptr(i))
+	}
+	if err := s.Push(64); err != concurrent.ErrTemporaryUnavailable {
+		t.Errorf("push expected ErrTemporaryUnavailable but got %v", err)
+		return
+	}
+
+	// use the stack as a free-list shared by several goroutines
+	owned := make([]atomic.Bool, 64)
+	wg := sync.WaitGroup{}
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 1 << 12 {
+				i := s.Pop()
+				if !owned[i].CompareAndSwap(false, true) {
+					t.Errorf("index %v popped twice", i)
+					return
+				}
+				owned[i].Store(false)
+				_ = s.Push(i)
+			}
+		}()
+	}
+	wg.Wait()
+	seen := make([]bool, 64)
+	for range 64 {
+		i, err := s.TryPop()
+		if err != nil || seen[i] {
+			t.Errorf("try pop expected a distinct index but got %v, %v", i, err)
+			return
+		}
+		seen[i] = true
+	}
+}
+
+func BenchmarkStack(b *testing.B) {
+	for _, n := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("Stack %d goroutines", n), func(b *testing.B) {
+			s := concurrent.NewStack[int]()
+			item := 0
+			wg := sync.WaitGroup{}
+			for range n {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < b.N/n; j++ {
+						_ = s.Push(&item)
+						_, _ = s.TryPop()
+					}
+				}()
+			}
+			wg.Wait()
+		})
+	}
+}
@@ -28,4 +28,14 @@ func DoubleUintPtr(first, second uintptr) (dw []uintptr) {
 	return unsafe.Slice((*uintptr)(addr), 2)
 }
 
+// alignedUint128s returns []uint64 holding n pairs of words
+// where every pair is 16-bytes aligned
+func alignedUint128s(n int) []uint64 {
+	mem := make([]uint64, 2*n+1)
+	ptr := uintptr(unsafe.Pointer(unsafe.SliceData(mem)))
+	off := (ptr & 0xf) / 8
+
+	return mem[off : off+uintptr(2*n)]
+}
+
 type noCopy struct{}
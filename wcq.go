@@ -0,0 +1,564 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package concurrent
+
+import (
+	"runtime"
+	"sync/atomic"
+
+	"golang.org/x/sys/cpu"
+)
+
+// wait-free version
+//
+// The fast path is the sCQ algorithm limited to wcqPatience attempts.
+// An operation running out of patience announces a request in the record
+// of its P and every operation of the same kind helps one announced request
+// every wcqHelpDelay operations, so the number of steps of each
+// Enqueue and Dequeue is bounded.
+//
+// Each entry is a 16-bytes aligned pair of words:
+// entry[0] holds cycle<<16 | record<<3 | pending<<2 | safe<<1 | occupied and
+// entry[1] holds the value. Cycles are 48-bit and compared with signed distances.
+// An entry inserted on behalf of an enqueue request stays pending
+// until the request is finalized at the position of the entry,
+// pending entries whose request is finalized elsewhere are discarded.
+//
+// Each request is a 16-bytes aligned pair of words:
+// request[0] holds position<<3 | status and request[1] holds the value.
+// Helpers assign new positions to a request with FAA followed by CAS,
+// a dequeue helper losing the CAS keeps the position for its own operation.
+//
+// Records are owned by Ps. An operation stays pinned to its P from the help
+// check until it leaves the slow path, since a goroutine preempted in between
+// would leave its request announced in a record which the next goroutine
+// of the same P takes over. The pinned section takes a bounded number of
+// steps and neither allocates nor blocks, so it does not delay preemption
+// and the GC longer than the wait-free bound of the operation itself.
+type wcq struct {
+	entries   []uint64
+	_         cpu.CacheLinePad
+	head      atomic.Uint64
+	_         cpu.CacheLinePad
+	tail      atomic.Uint64
+	_         cpu.CacheLinePad
+	threshold atomic.Int64
+	_         cpu.CacheLinePad
+	items     atomic.Int64
+	_         cpu.CacheLinePad
+
+	records  []wcqRecord
+	n        uint64
+	module   uint64
+	order    int
+	patience int
+}
+
+// wcqRecord is owned by a P, the helping schedule is accessed
+// with atomic loads and stores only to let the race detector know it
+type wcqRecord struct {
+	enq, deq  []uint64
+	nextCheck atomic.Int32
+	nextTid   atomic.Int32
+	_         cpu.CacheLinePad
+}
+
+const (
+	wcqOccupied  = 1 << 0
+	wcqSafe      = 1 << 1
+	wcqPending   = 1 << 2
+	wcqRecordBit = 3
+	wcqRecords   = 1 << 13
+	wcqCycleBit  = 16
+	wcqCycleMask = 1<<(64-wcqCycleBit) - 1
+
+	wcqIdle      = 0
+	wcqOpen      = 1
+	wcqFin       = 2
+	wcqEmpty     = 3
+	wcqAdvancing = 4
+	wcqStatusBit = 3
+
+	wcqPatience  = 16
+	wcqHelpDelay = 8
+)
+
+func newWCQ(order int) *wcq {
+	if order < 1 || order > 30 {
+		panic("bad capacity order")
+	}
+	ret := &wcq{
+		n:        1 << order,
+		order:    order,
+		patience: wcqPatience,
+	}
+	ret.module = 1 << max(0, ret.order+1-rmfLFModuleBit)
+	ret.head.Store(2 * ret.n)
+	ret.tail.Store(2 * ret.n)
+	ret.threshold.Store(-1)
+
+	ret.entries = alignedUint128s(int(2 * ret.n))
+	for i := range 2 * ret.n {
+		ret.entries[2*i] = wcqSafe
+	}
+	ret.records = make([]wcqRecord, min(runtime.GOMAXPROCS(0), wcqRecords))
+	for i := range ret.records {
+		ret.records[i].enq = alignedUint128s(1)
+		ret.records[i].deq = alignedUint128s(1)
+		ret.records[i].nextCheck.Store(wcqHelpDelay)
+		ret.records[i].nextTid.Store(int32((i + 1) % len(ret.records)))
+	}
+
+	return ret
+}
+
+func (q *wcq) offer(elem uint64) bool {
+	if q.items.Add(1) > int64(q.n) {
+		q.items.Add(-1)
+		return false
+	}
+	pid := procPin()
+	if pid >= len(q.records) {
+		// the P was added after the queue had been created,
+		// fall back to the lock-free fast path
+		procUnpin()
+		for !q.tryEnq(elem) {
+		}
+		return true
+	}
+	if tid, ok := q.helpee(pid); ok {
+		q.helpEnq(tid)
+	}
+	for range q.patience {
+		if q.tryEnq(elem) {
+			procUnpin()
+			return true
+		}
+	}
+	q.enqueueSlow(pid, elem)
+	procUnpin()
+
+	return true
+}
+
+func (q *wcq) poll() (elem uint64, ok bool) {
+	if q.threshold.Load() < 0 {
+		return 0, false
+	}
+	pid := procPin()
+	if pid >= len(q.records) {
+		procUnpin()
+		for {
+			h := q.head.Add(1) - 1
+			elem, ok = q.deqAt(h)
+			if ok {
+				q.items.Add(-1)
+				return
+			}
+			if q.failedAt(h) {
+				return 0, false
+			}
+		}
+	}
+	if tid, help := q.helpee(pid); help {
+		elem, ok = q.helpDeq(tid)
+		if ok {
+			procUnpin()
+			q.items.Add(-1)
+			return
+		}
+	}
+	for range q.patience {
+		h := q.head.Add(1) - 1
+		elem, ok = q.deqAt(h)
+		if ok {
+			procUnpin()
+			q.items.Add(-1)
+			return
+		}
+		if q.failedAt(h) {
+			procUnpin()
+			return 0, false
+		}
+	}
+	elem, ok = q.dequeueSlow(pid)
+	procUnpin()
+	if ok {
+		q.items.Add(-1)
+	}
+
+	return
+}
+
+// helpee returns the record to be helped by the P pid
+// once every wcqHelpDelay operations in round-robin
+func (q *wcq) helpee(pid int) (tid int, ok bool) {
+	r := &q.records[pid]
+	n := r.nextCheck.Load() - 1
+	if n > 0 {
+		r.nextCheck.Store(n)
+		return 0, false
+	}
+	r.nextCheck.Store(wcqHelpDelay)
+	tid = int(r.nextTid.Load())
+	r.nextTid.Store(int32((tid + 1) % len(q.records)))
+
+	return tid, true
+}
+
+// tryEnq performs a single attempt of the sCQ enqueue
+func (q *wcq) tryEnq(elem uint64) bool {
+	t := q.tail.Add(1) - 1
+	c := q.cycle(t)
+	e := q.entry(t)
+	for {
+		e0, e1 := atomic.LoadUint64(&e[0]), atomic.LoadUint64(&e[1])
+		if !q.before(e0>>wcqCycleBit, c) || e0&wcqOccupied != 0 {
+			return false
+		}
+		if e0&wcqSafe == 0 && q.head.Load() > t {
+			return false
+		}
+		if cas128(&e[0], [2]uint64{e0, e1}, [2]uint64{c<<wcqCycleBit | wcqSafe | wcqOccupied, elem}) {
+			q.resetThreshold()
+			return true
+		}
+	}
+}
+
+// deqAt performs the sCQ dequeue at the position h owned by the caller
+func (q *wcq) deqAt(h uint64) (elem uint64, ok bool) {
+	c := q.cycle(h)
+	e := q.entry(h)
+	for {
+		e0, e1 := atomic.LoadUint64(&e[0]), atomic.LoadUint64(&e[1])
+		ec := e0 >> wcqCycleBit
+		if ec == c && e0&wcqOccupied != 0 {
+			if e0&wcqPending != 0 {
+				q.resolve(e, e0, e1, h)
+				continue
+			}
+			if cas128(&e[0], [2]uint64{e0, e1}, [2]uint64{e0 &^ wcqOccupied, 0}) {
+				return e1, true
+			}
+			continue
+		}
+		if !q.before(ec, c) {
+			return 0, false
+		}
+		if cas128(&e[0], [2]uint64{e0, e1}, [2]uint64{q.spoiled(e0, c), e1}) {
+			return 0, false
+		}
+	}
+}
+
+// failedAt completes a failed dequeue attempt at the position h
+// and reports whether the queue is empty
+func (q *wcq) failedAt(h uint64) bool {
+	t := q.tail.Load()
+	if t <= h+1 {
+		q.catchup(t, h+1)
+		q.threshold.Add(-1)
+		return true
+	}
+
+	return q.threshold.Add(-1) < 0
+}
+
+func (q *wcq) enqueueSlow(pid int, elem uint64) {
+	req := q.records[pid].enq
+	t := q.tail.Add(1) - 1
+	atomic.StoreUint64(&req[1], elem)
+	atomic.StoreUint64(&req[0], t<<wcqStatusBit|wcqOpen)
+	for {
+		w0 := atomic.LoadUint64(&req[0])
+		pos := w0 >> wcqStatusBit
+		if w0&(1<<wcqStatusBit-1) == wcqFin {
+			q.unmark(pid, pos)
+			return
+		}
+		q.enqStep(pid, pos, elem)
+	}
+}
+
+func (q *wcq) dequeueSlow(pid int) (elem uint64, ok bool) {
+	req := q.records[pid].deq
+	h := q.head.Add(1) - 1
+	atomic.StoreUint64(&req[1], 0)
+	atomic.StoreUint64(&req[0], h<<wcqStatusBit|wcqOpen)
+	for {
+		w0, w1 := atomic.LoadUint64(&req[0]), atomic.LoadUint64(&req[1])
+		pos := w0 >> wcqStatusBit
+		switch w0 & (1<<wcqStatusBit - 1) {
+		case wcqFin:
+			q.consumed(pos)
+			return w1, true
+		case wcqEmpty:
+			return 0, false
+		case wcqOpen:
+			q.deqStep(req, pos, true)
+		}
+	}
+}
+
+// helpEnq helps the enqueue request of the record tid until it is finalized
+func (q *wcq) helpEnq(tid int) {
+	req := q.records[tid].enq
+	for {
+		w0, w1 := atomic.LoadUint64(&req[0]), atomic.LoadUint64(&req[1])
+		if w0&(1<<wcqStatusBit-1) != wcqOpen {
+			return
+		}
+		q.enqStep(tid, w0>>wcqStatusBit, w1)
+	}
+}
+
+// helpDeq helps the dequeue request of the record tid until it is finalized.
+// It returns the element of the position it assigned to the request
+// but could not assign because the request had moved on
+func (q *wcq) helpDeq(tid int) (elem uint64, ok bool) {
+	req := q.records[tid].deq
+	for {
+		w0 := atomic.LoadUint64(&req[0])
+		if w0&(1<<wcqStatusBit-1) != wcqOpen {
+			return 0, false
+		}
+		spare, has := q.deqStep(req, w0>>wcqStatusBit, false)
+		if has {
+			elem, ok = q.deqAt(spare)
+			if !ok {
+				q.failedAt(spare)
+			}
+			return
+		}
+	}
+}
+
+// enqStep performs one step of the enqueue request of the record tid at pos
+func (q *wcq) enqStep(tid int, pos uint64, elem uint64) {
+	req := q.records[tid].enq
+	open := pos<<wcqStatusBit | wcqOpen
+	c := q.cycle(pos)
+	e := q.entry(pos)
+	for {
+		e0, e1 := atomic.LoadUint64(&e[0]), atomic.LoadUint64(&e[1])
+		ec := e0 >> wcqCycleBit
+		if ec == c && e0&wcqOccupied != 0 {
+			q.finalize(tid, pos)
+			return
+		}
+		if !q.before(ec, c) || e0&wcqOccupied != 0 || e0&wcqSafe == 0 && q.head.Load() > pos {
+			if atomic.LoadUint64(&req[0]) == open {
+				t := q.tail.Add(1) - 1
+				cas128(&req[0], [2]uint64{open, elem}, [2]uint64{t<<wcqStatusBit | wcqOpen, elem})
+			}
+			return
+		}
+		if atomic.LoadUint64(&req[0]) != open {
+			return
+		}
+		pending := c<<wcqCycleBit | uint64(tid)<<wcqRecordBit | wcqPending | wcqSafe | wcqOccupied
+		if cas128(&e[0], [2]uint64{e0, e1}, [2]uint64{pending, elem}) {
+			q.resetThreshold()
+			if !q.finalize(tid, pos) {
+				cas128(&e[0], [2]uint64{pending, elem}, [2]uint64{c<<wcqCycleBit | wcqSafe, 0})
+			}
+			return
+		}
+	}
+}
+
+// deqStep performs one step of the dequeue request req at pos.
+// It returns a spare position if the caller is a helper which
+// took a new position but failed to assign it to the request
+func (q *wcq) deqStep(req []uint64, pos uint64, owner bool) (spare uint64, ok bool) {
+	open := pos<<wcqStatusBit | wcqOpen
+	c := q.cycle(pos)
+	e := q.entry(pos)
+	for {
+		e0, e1 := atomic.LoadUint64(&e[0]), atomic.LoadUint64(&e[1])
+		ec := e0 >> wcqCycleBit
+		if ec == c && e0&wcqOccupied != 0 {
+			if e0&wcqPending != 0 {
+				q.resolve(e, e0, e1, pos)
+				continue
+			}
+			// deliver the element to the request before removing it
+			cas128(&req[0], [2]uint64{open, 0}, [2]uint64{pos<<wcqStatusBit | wcqFin, e1})
+			if atomic.LoadUint64(&req[0]) != pos<<wcqStatusBit|wcqFin {
+				return 0, false
+			}
+			if cas128(&e[0], [2]uint64{e0, e1}, [2]uint64{e0 &^ wcqOccupied, 0}) {
+				return 0, false
+			}
+			continue
+		}
+		if atomic.LoadUint64(&req[0]) != open {
+			return 0, false
+		}
+		if q.before(ec, c) && !cas128(&e[0], [2]uint64{e0, e1}, [2]uint64{q.spoiled(e0, c), e1}) {
+			continue
+		}
+
+		return q.advance(req, pos, owner)
+	}
+}
+
+// advance moves the dequeue request req from the spoiled position pos
+// to a new position or finalizes it as empty
+func (q *wcq) advance(req []uint64, pos uint64, owner bool) (spare uint64, ok bool) {
+	cur := pos<<wcqStatusBit | wcqOpen
+	if owner {
+		// the owner never holds a spare position,
+		// helpers leave a request alone while its owner advances it
+		next := pos<<wcqStatusBit | wcqAdvancing
+		if !cas128(&req[0], [2]uint64{cur, 0}, [2]uint64{next, 0}) {
+			return 0, false
+		}
+		cur = next
+	}
+	empty := pos<<wcqStatusBit | wcqEmpty
+	t := q.tail.Load()
+	if t <= pos+1 {
+		q.catchup(t, pos+1)
+		q.threshold.Add(-1)
+		cas128(&req[0], [2]uint64{cur, 0}, [2]uint64{empty, 0})
+		return 0, false
+	}
+	if q.threshold.Load() < 0 {
+		cas128(&req[0], [2]uint64{cur, 0}, [2]uint64{empty, 0})
+		return 0, false
+	}
+	h := q.head.Add(1) - 1
+	if cas128(&req[0], [2]uint64{cur, 0}, [2]uint64{h<<wcqStatusBit | wcqOpen, 0}) {
+		q.threshold.Add(-1)
+		return 0, false
+	}
+
+	return h, true
+}
+
+// finalize finalizes the enqueue request of the record tid at pos
+// and reports whether the request is finalized at pos
+func (q *wcq) finalize(tid int, pos uint64) bool {
+	req := q.records[tid].enq
+	open, fin := pos<<wcqStatusBit|wcqOpen, pos<<wcqStatusBit|wcqFin
+	for {
+		w0, w1 := atomic.LoadUint64(&req[0]), atomic.LoadUint64(&req[1])
+		if w0 == fin {
+			return true
+		}
+		if w0 != open {
+			return false
+		}
+		if cas128(&req[0], [2]uint64{w0, w1}, [2]uint64{fin, w1}) {
+			return true
+		}
+	}
+}
+
+// resolve turns the pending entry e at pos into an ordinary entry
+// if its request is finalized at pos, otherwise discards it
+func (q *wcq) resolve(e []uint64, e0, e1 uint64, pos uint64) {
+	tid := int(e0>>wcqRecordBit) & (wcqRecords - 1)
+	if q.finalize(tid, pos) {
+		cas128(&e[0], [2]uint64{e0, e1}, [2]uint64{e0 &^ wcqPending, e1})
+		return
+	}
+	cas128(&e[0], [2]uint64{e0, e1}, [2]uint64{e0>>wcqCycleBit<<wcqCycleBit | e0&wcqSafe, 0})
+}
+
+// unmark clears the pending flag of the entry at pos inserted for
+// the enqueue request of the record tid, the record must not be reused before
+func (q *wcq) unmark(tid int, pos uint64) {
+	c := q.cycle(pos)
+	e := q.entry(pos)
+	for {
+		e0, e1 := atomic.LoadUint64(&e[0]), atomic.LoadUint64(&e[1])
+		if e0>>wcqCycleBit != c || e0&wcqPending == 0 || int(e0>>wcqRecordBit)&(wcqRecords-1) != tid {
+			return
+		}
+		if cas128(&e[0], [2]uint64{e0, e1}, [2]uint64{e0 &^ wcqPending, e1}) {
+			return
+		}
+	}
+}
+
+// consumed removes the element at pos delivered to a dequeue request
+func (q *wcq) consumed(pos uint64) {
+	c := q.cycle(pos)
+	e := q.entry(pos)
+	for {
+		e0, e1 := atomic.LoadUint64(&e[0]), atomic.LoadUint64(&e[1])
+		if e0>>wcqCycleBit != c || e0&wcqOccupied == 0 {
+			return
+		}
+		if cas128(&e[0], [2]uint64{e0, e1}, [2]uint64{e0 &^ wcqOccupied, 0}) {
+			return
+		}
+	}
+}
+
+// spoiled returns the entry marked unsafe if it is occupied by a previous cycle,
+// otherwise the entry advanced to the cycle c so that late enqueuers skip it
+func (q *wcq) spoiled(e0 uint64, c uint64) uint64 {
+	if e0&wcqOccupied != 0 {
+		return e0 &^ wcqSafe
+	}
+	return c<<wcqCycleBit | e0&wcqSafe
+}
+
+func (q *wcq) catchup(tail, head uint64) {
+	for !q.tail.CompareAndSwap(tail, head) {
+		head, tail = q.head.Load(), q.tail.Load()
+		if tail >= head {
+			return
+		}
+	}
+}
+
+func (q *wcq) resetThreshold() {
+	if q.threshold.Load() != int64(3*q.n-1) {
+		q.threshold.Store(int64(3*q.n - 1))
+	}
+}
+
+// before reports whether cycle a precedes cycle b
+func (q *wcq) before(a, b uint64) bool {
+	return int64((a-b)<<wcqCycleBit) < 0
+}
+
+// cycle returns the cycle of the given head or tail position
+func (q *wcq) cycle(pos uint64) uint64 {
+	return pos >> (q.order + 1) & wcqCycleMask
+}
+
+func (q *wcq) entry(index uint64) []uint64 {
+	index &= 2*q.n - 1
+	p, r := index>>rmfLFModuleBit, index&rmfLFModuleMask
+	i := r*q.module + p
+
+	return q.entries[2*i : 2*i+2]
+}
+
+// Len returns the approximate number of queued elements
+func (q *wcq) Len() int {
+	return clampLen(q.items.Load(), int(q.n))
+}
+
+// Cap returns the capacity of the queue
+func (q *wcq) Cap() int {
+	return int(q.n)
+}
+
+// IsEmpty reports whether the queue is approximately empty
+func (q *wcq) IsEmpty() bool {
+	return q.items.Load() <= 0
+}
+
+// IsFull reports whether the queue is approximately full
+func (q *wcq) IsFull() bool {
+	return q.items.Load() >= int64(int(q.n))
+}
@@ -0,0 +1,74 @@
+// ©Hayabusa Cloud Co., Ltd. 2025. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package concurrent
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWCQSlowPath(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	for _, order := range []int{1, 3, 8} {
+		q := newWCQ(order)
+		q.patience = order % 2
+		const n, cn, pn = 1 << 12, 4, 4
+		for i := range pn {
+			go func(i int) {
+				for j := range n {
+					for !q.offer(uint64(i*n + j)) {
+						runtime.Gosched()
+					}
+				}
+			}(i)
+		}
+		seen := make([]atomic.Bool, n*pn)
+		last := make([]atomic.Int64, pn*cn)
+		for i := range last {
+			last[i].Store(-1)
+		}
+		// a lost value would keep a consumer polling forever,
+		// so consumers give up after the deadline
+		deadline := time.Now().Add(10 * time.Second)
+		dequeued := atomic.Int64{}
+		wg := sync.WaitGroup{}
+		for i := range cn {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for range n * pn / cn {
+					v, ok := q.poll()
+					for !ok {
+						if time.Now().After(deadline) {
+							return
+						}
+						runtime.Gosched()
+						v, ok = q.poll()
+					}
+					dequeued.Add(1)
+					if seen[v].Swap(true) {
+						t.Errorf("value %d dequeued twice", v)
+						return
+					}
+					p, s := int(v)/n, int64(v)%n
+					if old := last[i*pn+p].Swap(s); old >= s {
+						t.Errorf("value %d dequeued after %d", s, old)
+						return
+					}
+				}
+			}(i)
+		}
+		wg.Wait()
+		if dequeued.Load() != n*pn {
+			t.Fatalf("order %d expected %d dequeued values but got %d", order, n*pn, dequeued.Load())
+		}
+		if _, ok := q.poll(); ok {
+			t.Errorf("poll expected empty queue")
+		}
+	}
+}